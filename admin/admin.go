@@ -0,0 +1,218 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zigmahq/zigma/store"
+)
+
+// the trailers carrying the outcome of a backup, which is only known once the
+// backup has been streamed
+const (
+	trailerVersion = "Zigma-Backup-Version"
+	trailerError   = "Zigma-Backup-Error"
+)
+
+// ErrNotRunning is returned by Dial when no node listens on the socket
+var ErrNotRunning = errors.New("no running node listens on the admin socket")
+
+// Quiescer holds the writers of a store, such as the dht of the node, while a
+// backup is restored into the store
+type Quiescer interface {
+	Pause()
+	Resume()
+}
+
+// Server serves the database commands of a running node over a unix socket, so
+// that the database can be backed up and restored while the node holds the
+// lock of the database directory
+type Server struct {
+	path string
+	l    net.Listener
+	srv  *http.Server
+}
+
+// Listen serves the store on a unix socket at the path, a socket left behind
+// by a node which did not shut down cleanly is replaced. The writers of the
+// store are paused with q, if any, while a backup is restored
+func Listen(path string, s store.Store, q Quiescer) (*Server, error) {
+	if c, err := Dial(path); err == nil {
+		c.Close()
+		return nil, errors.New("admin socket is in use by another node")
+	}
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	srv := &Server{
+		path: path,
+		l:    l,
+		srv:  &http.Server{Handler: handler(s, q)},
+	}
+	go srv.srv.Serve(l)
+	return srv, nil
+}
+
+// Close stops serving the store, and removes the socket
+func (s *Server) Close() error {
+	err := s.srv.Close()
+	os.Remove(s.path)
+	return err
+}
+
+// handler routes the database commands to the store
+func handler(s store.Store, q Quiescer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/db/backup", func(w http.ResponseWriter, r *http.Request) {
+		since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid since version", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Trailer", trailerVersion+", "+trailerError)
+		next, err := s.Backup(w, since)
+		if err != nil {
+			w.Header().Set(trailerError, err.Error())
+			return
+		}
+		w.Header().Set(trailerVersion, strconv.FormatUint(next, 10))
+	})
+	mux.HandleFunc("/db/restore", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "restore requires a POST request", http.StatusMethodNotAllowed)
+			return
+		}
+		if q != nil {
+			q.Pause()
+			defer q.Resume()
+		}
+		if err := s.Restore(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	mux.HandleFunc("/db/stats", func(w http.ResponseWriter, r *http.Request) {
+		window, err := time.ParseDuration(r.URL.Query().Get("window"))
+		if err != nil {
+			http.Error(w, "invalid window", http.StatusBadRequest)
+			return
+		}
+		stats := store.ReadStats(s, window)
+		stats.WriteJSON(w)
+	})
+	return mux
+}
+
+// Client sends database commands to a running node
+type Client struct {
+	http      *http.Client
+	transport *http.Transport
+}
+
+// Dial connects to the node listening on the unix socket at the path, returns
+// ErrNotRunning if no node listens on it
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	conn.Close()
+
+	dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", path)
+	}
+	transport := &http.Transport{DialContext: dial}
+	return &Client{
+		http:      &http.Client{Transport: transport},
+		transport: transport,
+	}, nil
+}
+
+// Close releases the connections of the client to the node
+func (c *Client) Close() {
+	c.transport.CloseIdleConnections()
+}
+
+// Backup writes the keys written at or after the since version, and returns
+// the version to pass as since to the next incremental backup
+func (c *Client) Backup(w io.Writer, since uint64) (uint64, error) {
+	resp, err := c.http.Get("http://admin/db/backup?since=" + strconv.FormatUint(since, 10))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return 0, err
+	}
+	if msg := resp.Trailer.Get(trailerError); len(msg) > 0 {
+		return 0, errors.New(msg)
+	}
+	return strconv.ParseUint(resp.Trailer.Get(trailerVersion), 10, 64)
+}
+
+// Restore loads the keys of a backup into the database of the node, the dht
+// of the node is paused while the backup loads. The restored keys are not
+// delivered to watches of the node
+func (c *Client) Restore(r io.Reader) error {
+	resp, err := c.http.Post("http://admin/db/restore", "application/octet-stream", r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return responseError(resp)
+}
+
+// Stats reads the usage of the database of the node by namespace
+func (c *Client) Stats(window time.Duration) (store.Stats, error) {
+	var stats store.Stats
+	resp, err := c.http.Get("http://admin/db/stats?window=" + window.String())
+	if err != nil {
+		return stats, err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return stats, err
+	}
+	err = json.NewDecoder(resp.Body).Decode(&stats)
+	return stats, err
+}
+
+// responseError returns the error reported by the node, if any
+func responseError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	return errors.New(strings.TrimSpace(string(b)))
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package admin_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/admin"
+	"github.com/zigmahq/zigma/store"
+)
+
+// quiescer counts the pauses of the writers of a store
+type quiescer struct {
+	paused, resumed int
+}
+
+func (q *quiescer) Pause()  { q.paused++ }
+func (q *quiescer) Resume() { q.resumed++ }
+
+func TestAdminBackupRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zigma-admin")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	src := store.NewMemoryStore()
+	defer src.Close()
+	assert.Nil(t, src.Set([]byte("a"), []byte("1"), 0))
+
+	path := filepath.Join(dir, "src.sock")
+	srv, err := admin.Listen(path, src, nil)
+	assert.Nil(t, err)
+	defer srv.Close()
+
+	// a second node cannot take over the socket of a running node
+	_, err = admin.Listen(path, src, nil)
+	assert.NotNil(t, err)
+
+	c, err := admin.Dial(path)
+	assert.Nil(t, err)
+	defer c.Close()
+
+	var full bytes.Buffer
+	since, err := c.Backup(&full, 0)
+	assert.Nil(t, err)
+	assert.True(t, since > 0)
+
+	assert.Nil(t, src.Set([]byte("b"), []byte("2"), 0))
+	var incr bytes.Buffer
+	next, err := c.Backup(&incr, since)
+	assert.Nil(t, err)
+	assert.True(t, next > since)
+
+	dst := store.NewMemoryStore()
+	defer dst.Close()
+	dpath := filepath.Join(dir, "dst.sock")
+	q := new(quiescer)
+	dsrv, err := admin.Listen(dpath, dst, q)
+	assert.Nil(t, err)
+	defer dsrv.Close()
+
+	d, err := admin.Dial(dpath)
+	assert.Nil(t, err)
+	defer d.Close()
+	assert.Nil(t, d.Restore(&full))
+	assert.Nil(t, d.Restore(&incr))
+
+	// the writers of the store are paused for every restore
+	assert.Equal(t, 2, q.paused)
+	assert.Equal(t, 2, q.resumed)
+
+	for k, v := range map[string]string{"a": "1", "b": "2"} {
+		b, ok := dst.Get([]byte(k))
+		assert.True(t, ok)
+		assert.Equal(t, []byte(v), b)
+	}
+
+	stats, err := d.Stats(time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, stats.Keys)
+	assert.Equal(t, time.Hour, stats.Window)
+}
+
+func TestAdminNotRunning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zigma-admin")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "admin.sock")
+	_, err = admin.Dial(path)
+	assert.Equal(t, admin.ErrNotRunning, err)
+
+	// the socket is removed once the node stops
+	srv, err := admin.Listen(path, store.NewMemoryStore(), nil)
+	assert.Nil(t, err)
+	assert.Nil(t, srv.Close())
+	_, err = admin.Dial(path)
+	assert.Equal(t, admin.ErrNotRunning, err)
+}
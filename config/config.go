@@ -8,14 +8,16 @@ import (
 
 // Config encapsulates all configuration details for zigma
 type Config struct {
-	path string
-	P2P  *P2P `yaml:"p2p"`
+	path    string
+	P2P     *P2P     `yaml:"p2p"`
+	Storage *Storage `yaml:"storage"`
 }
 
 // DefaultConfig generates the default settings for zigma
 func DefaultConfig() *Config {
 	return &Config{
-		P2P: DefaultP2P(),
+		P2P:     DefaultP2P(),
+		Storage: DefaultStorage(),
 	}
 }
 
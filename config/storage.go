@@ -0,0 +1,227 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zigmahq/zigma/store"
+)
+
+// Errors for the storage configuration
+var (
+	ErrNoEncryptionKey = errors.New("encryption requires a key file or a passphrase")
+	ErrUnknownBackend  = errors.New("unknown storage backend")
+	ErrUnknownEviction = errors.New("unknown eviction policy")
+)
+
+// StorageBackend is the implementation of the node database
+type StorageBackend string
+
+// Define storage backends
+const (
+	BackendBadger StorageBackend = "badger"
+	BackendMemory StorageBackend = "memory"
+)
+
+// Storage encapsulates configuration options for the node database
+type Storage struct {
+	Backend            StorageBackend `yaml:"backend"`               // badger, or memory to keep no data on disk
+	Path               string         `yaml:"path"`                  // the directory of the database
+	SyncWrites         bool           `yaml:"sync_writes"`           // sync every write to disk
+	ValueLogFileSize   int64          `yaml:"value_log_file_size"`   // the maximum size of a value log file
+	ValueLogMaxEntries uint32         `yaml:"value_log_max_entries"` // the maximum number of entries of a value log file
+	InMemory           bool           `yaml:"in_memory"`             // load the lsm tree to memory
+	Capacity           int64          `yaml:"capacity"`              // the bytes of keys and values kept at most, 0 for no limit
+	Eviction           string         `yaml:"eviction"`              // the eviction policy, lru or nearest-expiry
+	Protected          []string       `yaml:"protected"`             // namespaces which are never evicted
+	GC                 *GC            `yaml:"gc"`                    // settings for value log garbage collection
+	Encryption         *Encryption    `yaml:"encryption"`            // settings for encryption at rest
+	Scrub              *Scrub         `yaml:"scrub"`                 // settings for integrity scrubbing of dht values
+	Admin              string         `yaml:"admin"`                 // the unix socket serving database commands of a running node
+}
+
+// GC encapsulates configuration options for value log garbage collection
+type GC struct {
+	Interval time.Duration `yaml:"interval"` // the interval between collections
+	Ratio    float64       `yaml:"ratio"`    // the ratio of discardable data rewriting a file
+}
+
+// Scrub encapsulates configuration options for integrity scrubbing, the values
+// keyed by their multihash are verified and repaired from the network
+type Scrub struct {
+	Enable     bool          `yaml:"enable"`
+	Rate       int           `yaml:"rate"`       // the number of values verified per second
+	Interval   time.Duration `yaml:"interval"`   // the interval between passes over the store
+	Quarantine bool          `yaml:"quarantine"` // keep corrupt values for inspection rather than dropping them
+}
+
+// Encryption encapsulates configuration options for encryption at rest, the
+// key is read from a key file or derived from a passphrase. Keys being rotated
+// out are kept as previous keys until the values are re-encrypted
+type Encryption struct {
+	Enable              bool     `yaml:"enable"`
+	KeyFile             string   `yaml:"key_file"`             // the path of a 32 byte key
+	Passphrase          string   `yaml:"passphrase"`           // derive the key from a passphrase
+	PreviousKeyFiles    []string `yaml:"previous_key_files"`   // key files being rotated out
+	PreviousPassphrases []string `yaml:"previous_passphrases"` // passphrases being rotated out
+}
+
+// DefaultStorage generates the default configuration for the node database
+func DefaultStorage() *Storage {
+	opts := store.DefaultBadgerOptions("zigma.db")
+	return &Storage{
+		Backend:            BackendBadger,
+		Path:               opts.Path,
+		SyncWrites:         opts.SyncWrites,
+		ValueLogFileSize:   opts.ValueLogFileSize,
+		ValueLogMaxEntries: opts.ValueLogMaxEntries,
+		InMemory:           false,
+		Capacity:           0,
+		Eviction:           string(store.EvictLRU),
+		GC:                 DefaultGC(),
+		Encryption:         DefaultEncryption(),
+		Scrub:              DefaultScrub(),
+	}
+}
+
+// DefaultGC generates the default configuration for garbage collection
+func DefaultGC() *GC {
+	return &GC{
+		Interval: 5 * time.Minute,
+		Ratio:    0.7,
+	}
+}
+
+// DefaultScrub generates the default configuration for integrity scrubbing
+func DefaultScrub() *Scrub {
+	return &Scrub{
+		Enable:     true,
+		Rate:       100,
+		Interval:   6 * time.Hour,
+		Quarantine: false,
+	}
+}
+
+// DefaultEncryption generates the default configuration for encryption at rest
+func DefaultEncryption() *Encryption {
+	return &Encryption{
+		Enable: false,
+	}
+}
+
+// Keyring loads the encryption keys, returns nil if encryption is disabled.
+// The salt of passphrase derived keys is kept in the database directory
+func (s *Storage) Keyring() (*store.Keyring, error) {
+	e := s.Encryption
+	if e == nil || !e.Enable {
+		return nil, nil
+	}
+
+	var salt []byte
+	if len(e.Passphrase) > 0 || len(e.PreviousPassphrases) > 0 {
+		var err error
+		if salt, err = store.ReadSaltFile(filepath.Join(s.Path, "KEYSALT")); err != nil {
+			return nil, err
+		}
+	}
+
+	var keys [][]byte
+	if len(e.KeyFile) > 0 {
+		key, err := store.ReadKeyFile(e.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	} else if len(e.Passphrase) > 0 {
+		keys = append(keys, store.PassphraseKey(e.Passphrase, salt))
+	} else {
+		return nil, ErrNoEncryptionKey
+	}
+	for _, path := range e.PreviousKeyFiles {
+		key, err := store.ReadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	for _, passphrase := range e.PreviousPassphrases {
+		keys = append(keys, store.PassphraseKey(passphrase, salt))
+	}
+	return store.NewKeyring(keys[0], keys[1:]...)
+}
+
+// AdminSocket returns the path of the unix socket serving the database commands
+// of a running node, admin.sock in the database directory if none is set
+func (s *Storage) AdminSocket() string {
+	if len(s.Admin) > 0 {
+		return s.Admin
+	}
+	return filepath.Join(s.Path, "admin.sock")
+}
+
+// Open opens the database of the node with the configured backend, bounded by
+// the capacity if one is set, and wrapped with encryption at rest if it is
+// enabled. The memory backend keeps no data on disk, and is never encrypted.
+// The operations on the backend are counted for store.ReadStats
+func (s *Storage) Open() (store.Store, error) {
+	switch store.EvictionPolicy(s.Eviction) {
+	case store.EvictLRU, store.EvictNearestExpiry, "":
+	default:
+		return nil, ErrUnknownEviction
+	}
+
+	var (
+		db   store.Store
+		keys *store.Keyring
+	)
+	switch s.Backend {
+	case BackendBadger, "":
+		if err := os.MkdirAll(s.Path, 0700); err != nil {
+			return nil, err
+		}
+		var err error
+		if keys, err = s.Keyring(); err != nil {
+			return nil, err
+		}
+		if db, err = store.NewBadgerStoreWithOptions(s.badgerOptions()); err != nil {
+			return nil, err
+		}
+	case BackendMemory:
+		db = store.NewMemoryStore()
+	default:
+		return nil, ErrUnknownBackend
+	}
+
+	db = store.NewStatsStore(db)
+	if s.Capacity > 0 {
+		db = store.NewBoundedStore(db, store.BoundedOptions{
+			Capacity:  s.Capacity,
+			Policy:    store.EvictionPolicy(s.Eviction),
+			Protected: s.Protected,
+		})
+	}
+	if keys != nil {
+		es, err := store.NewEncryptedStore(db, keys)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		db = es
+	}
+	return db, nil
+}
+
+func (s *Storage) badgerOptions() store.BadgerOptions {
+	opts := store.DefaultBadgerOptions(s.Path)
+	opts.SyncWrites = s.SyncWrites
+	opts.ValueLogFileSize = s.ValueLogFileSize
+	opts.ValueLogMaxEntries = s.ValueLogMaxEntries
+	opts.InMemory = s.InMemory
+	if s.GC != nil {
+		opts.GCInterval = s.GC.Interval
+		opts.GCRatio = s.GC.Ratio
+	}
+	return opts
+}
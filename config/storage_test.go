@@ -0,0 +1,126 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/config"
+	"github.com/zigmahq/zigma/store"
+)
+
+var storagecfg = []byte(`
+storage:
+  backend: badger
+  path: /var/lib/zigma
+  sync_writes: false
+  value_log_file_size: 268435456
+  in_memory: true
+  capacity: 1073741824
+  eviction: nearest-expiry
+  protected:
+    - app
+  gc:
+    interval: 10m
+    ratio: 0.5
+  encryption:
+    enable: true
+    passphrase: correct horse battery staple
+  scrub:
+    enable: true
+    rate: 50
+    interval: 1h
+    quarantine: true
+  admin: /run/zigma/admin.sock
+`)
+
+func TestStorageUnmarshal(t *testing.T) {
+	c, err := config.Read(storagecfg)
+
+	assert.Nil(t, err)
+	assert.Equal(t, config.BackendBadger, c.Storage.Backend)
+	assert.Equal(t, "/var/lib/zigma", c.Storage.Path)
+	assert.False(t, c.Storage.SyncWrites)
+	assert.Equal(t, int64(268435456), c.Storage.ValueLogFileSize)
+	assert.Equal(t, uint32(1000000), c.Storage.ValueLogMaxEntries)
+	assert.True(t, c.Storage.InMemory)
+	assert.Equal(t, int64(1073741824), c.Storage.Capacity)
+	assert.Equal(t, "nearest-expiry", c.Storage.Eviction)
+	assert.Equal(t, []string{"app"}, c.Storage.Protected)
+	assert.Equal(t, time.Minute*10, c.Storage.GC.Interval)
+	assert.Equal(t, 0.5, c.Storage.GC.Ratio)
+	assert.True(t, c.Storage.Encryption.Enable)
+	assert.Equal(t, "correct horse battery staple", c.Storage.Encryption.Passphrase)
+	assert.True(t, c.Storage.Scrub.Enable)
+	assert.Equal(t, 50, c.Storage.Scrub.Rate)
+	assert.Equal(t, time.Hour, c.Storage.Scrub.Interval)
+	assert.True(t, c.Storage.Scrub.Quarantine)
+	assert.Equal(t, "/run/zigma/admin.sock", c.Storage.AdminSocket())
+
+	c.Storage.Admin = ""
+	assert.Equal(t, "/var/lib/zigma/admin.sock", c.Storage.AdminSocket())
+}
+
+func TestStorageKeyring(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	s := config.DefaultStorage()
+	s.Path = dir
+
+	keys, err := s.Keyring()
+	assert.Nil(t, err)
+	assert.Nil(t, keys)
+
+	s.Encryption.Enable = true
+	_, err = s.Keyring()
+	assert.Equal(t, config.ErrNoEncryptionKey, err)
+
+	s.Encryption.Passphrase = "passphrase"
+	keys, err = s.Keyring()
+	assert.Nil(t, err)
+	assert.NotNil(t, keys)
+
+	db, err := s.Open()
+	assert.Nil(t, err)
+	assert.Nil(t, db.Set([]byte("key"), []byte("value"), 0))
+	db.Close()
+
+	// a wrong passphrase fails to open the database
+	s.Encryption.Passphrase = "wrong passphrase"
+	_, err = s.Open()
+	assert.Equal(t, store.ErrWrongKey, err)
+
+	s.Encryption.Passphrase = "passphrase"
+	db, err = s.Open()
+	assert.Nil(t, err)
+	defer db.Close()
+	v, ok := db.Get([]byte("key"))
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), v)
+}
+
+func TestStorageBackend(t *testing.T) {
+	s := config.DefaultStorage()
+	s.Backend = config.BackendMemory
+
+	db, err := s.Open()
+	assert.Nil(t, err)
+	assert.Nil(t, db.Set([]byte("key"), []byte("value"), 0))
+	stats := store.ReadStats(db, time.Hour)
+	assert.Equal(t, 1, stats.Keys)
+	assert.Equal(t, uint64(1), stats.Namespaces[0].Writes)
+	db.Close()
+
+	s.Backend = "leveldb"
+	_, err = s.Open()
+	assert.Equal(t, config.ErrUnknownBackend, err)
+
+	s.Backend = config.BackendMemory
+	s.Eviction = "random"
+	_, err = s.Open()
+	assert.Equal(t, config.ErrUnknownEviction, err)
+}
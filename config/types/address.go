@@ -10,6 +10,9 @@ import (
 	"github.com/multiformats/go-multiaddr"
 )
 
+// NameScheme is the url scheme of addresses referring to registered names
+const NameScheme = "zigma"
+
 // Addrs type
 type Addrs []*Addr
 
@@ -34,6 +37,9 @@ func (a *Addrs) UnmarshalYAML(unmarshal func(interface{}) error) error {
 func (a Addrs) PeerInfos() []peerstore.PeerInfo {
 	var addrs []multiaddr.Multiaddr
 	for _, addr := range a {
+		if addr.IsName() {
+			continue
+		}
 		m, err := addr.Multiaddr()
 		if err != nil {
 			continue
@@ -71,6 +77,17 @@ func (a *Addr) MarshalYAML() (interface{}, error) {
 	return a.URL.String(), nil
 }
 
+// IsName checks if the address refers to a node by its registered name, such
+// as zigma://pretty-dress-0716
+func (a *Addr) IsName() bool {
+	return a.URL.Scheme == NameScheme
+}
+
+// Name returns the registered name of a name address
+func (a *Addr) Name() string {
+	return a.URL.Hostname()
+}
+
 // Protocol returns address protocol name
 func (a *Addr) Protocol() string {
 	hn := a.URL.Hostname()
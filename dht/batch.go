@@ -0,0 +1,207 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// the maximum number of keys carried by a single STORE_MANY or FIND_MANY
+const batchSize = 128
+
+// StoreMany stores a batch of values on the network. Values are grouped by the
+// prefix of their hash, a single lookup is made for every group, and each of
+// the closest nodes receives the values of a group in STORE_MANY requests.
+// Returns the number of nodes which stored every value, in order
+func (kad *Kademlia) StoreMany(data ...Hashable) []int {
+	writes := make([]int, len(data))
+	targets := make([][]byte, len(data))
+	for i, d := range data {
+		if d != nil {
+			targets[i] = d.Hash()
+		}
+	}
+
+	for _, group := range kad.groupByPrefix(targets) {
+		contacts := kad.iterativeFindNode(targets[group[0]])
+		if contacts.Len() == 0 {
+			continue
+		}
+		for _, chunk := range chunkIndices(group, batchSize) {
+			payloads := make([]Hashable, len(chunk))
+			for i, idx := range chunk {
+				payloads[i] = data[idx]
+			}
+
+			var (
+				mu sync.Mutex
+				wg sync.WaitGroup
+			)
+			for _, node := range contacts.Nodes() {
+				wg.Add(1)
+				go func(node *Node) {
+					defer wg.Done()
+					msg := compose(kad.table.Self).to(node).storeMany(payloads)
+					rec := kad.write(msg)
+					out := <-rec(0)
+					if out == nil {
+						return
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					for i, ok := range out.GetStored().GetStored() {
+						if ok && i < len(chunk) {
+							writes[chunk[i]]++
+						}
+					}
+				}(node)
+			}
+			wg.Wait()
+		}
+	}
+	return writes
+}
+
+// FindMany retrieves a batch of values from the network. Keys are grouped by
+// their prefix, a single lookup is made for every group, and the closest nodes
+// are asked for the keys of a group in FIND_MANY requests. Keys which are not
+// found this way fall back to FindValue. Returns the values in the order of the
+// keys, missing values are nil
+func (kad *Kademlia) FindMany(keys ...[]byte) [][]byte {
+	var (
+		values = make([][]byte, len(keys))
+		found  = make([]*Payload, len(keys))
+	)
+	for _, group := range kad.groupByPrefix(keys) {
+		pending := group
+		contacts := kad.iterativeFindNode(keys[group[0]])
+		for _, node := range contacts.Nodes() {
+			if len(pending) == 0 {
+				break
+			}
+			for _, chunk := range chunkIndices(pending, batchSize) {
+				kad.findManyAt(node, keys, chunk, found)
+			}
+			pending = missingIndices(pending, found)
+		}
+		for _, idx := range pending {
+			values[idx], _ = kad.FindValue(keys[idx])
+		}
+	}
+
+	for i, p := range found {
+		if p == nil {
+			continue
+		}
+		if manifest, ok := decodeShardManifest(p.Kind, p.Data); ok {
+			values[i], _ = kad.rebuildErasure(keys[i], manifest)
+		} else {
+			values[i] = p.Data
+		}
+	}
+	return values
+}
+
+// findManyAt asks a node for the keys at the given indices, the payloads
+// returned are placed at the index of their key
+func (kad *Kademlia) findManyAt(node *Node, keys [][]byte, indices []int, found []*Payload) {
+	req := make([][]byte, len(indices))
+	lookup := make(map[string][]int, len(indices))
+	for i, idx := range indices {
+		req[i] = keys[idx]
+		lookup[string(keys[idx])] = append(lookup[string(keys[idx])], idx)
+	}
+
+	msg := compose(kad.table.Self).to(node).findMany(req)
+	rec := kad.write(msg)
+	out := <-rec(0)
+	if out == nil {
+		return
+	}
+	for _, payload := range out.GetValues().GetPayloads() {
+		for _, idx := range lookup[string(payload.GetKey())] {
+			found[idx] = payload
+		}
+	}
+}
+
+// groupByPrefix groups the indices of the keys which share a prefix as long as
+// the depth of the routing table, such keys are likely to share their closest
+// nodes. Nil keys are left out
+func (kad *Kademlia) groupByPrefix(keys [][]byte) [][]int {
+	var indices []int
+	for i, key := range keys {
+		if len(key) > 0 {
+			indices = append(indices, i)
+		}
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return bytes.Compare(keys[indices[i]], keys[indices[j]]) < 0
+	})
+
+	var (
+		groups [][]int
+		bits   = kad.table.depth() + 1
+	)
+	for i, idx := range indices {
+		if i == 0 || !sharePrefix(keys[indices[i-1]], keys[idx], bits) {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], idx)
+	}
+	return groups
+}
+
+// sharePrefix checks if two keys share the first bits
+func sharePrefix(a, b []byte, bits int) bool {
+	for i := 0; i < bits; i++ {
+		q, m := i/8, uint(i%8)
+		if q >= len(a) || q >= len(b) {
+			return len(a) == len(b)
+		}
+		if (a[q]^b[q])&(0x80>>m) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// chunkIndices splits the indices into chunks of at most n indices
+func chunkIndices(indices []int, n int) [][]int {
+	var chunks [][]int
+	for i := 0; i < len(indices); i += n {
+		j := i + n
+		if j > len(indices) {
+			j = len(indices)
+		}
+		chunks = append(chunks, indices[i:j])
+	}
+	return chunks
+}
+
+// missingIndices returns the indices which have no payload yet
+func missingIndices(indices []int, found []*Payload) []int {
+	var out []int
+	for _, idx := range indices {
+		if found[idx] == nil {
+			out = append(out, idx)
+		}
+	}
+	return out
+}
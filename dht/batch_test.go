@@ -0,0 +1,117 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/dht"
+)
+
+func TestKademliaStoreManyFindMany(t *testing.T) {
+	kads, _, done := mockNetwork(2400, 8)
+	defer done()
+
+	var (
+		data = make([]dht.Hashable, 300)
+		keys = make([][]byte, len(data))
+	)
+	for i := range data {
+		data[i] = dht.String(fmt.Sprintf("batched value %d", i))
+		keys[i] = data[i].Key()
+	}
+
+	writes := kads[0].StoreMany(data...)
+	assert.Len(t, writes, len(data))
+	for _, w := range writes {
+		assert.True(t, w > 0)
+	}
+
+	b, ok := kads[2].FindValue(keys[42])
+	assert.True(t, ok)
+	assert.Equal(t, data[42].Data(), b)
+
+	missing := dht.String("never stored").Key()
+	query := append(keys, missing, keys[0])
+	values := kads[1].FindMany(query...)
+	assert.Len(t, values, len(query))
+	for i := range data {
+		assert.Equal(t, data[i].Data(), values[i])
+	}
+	assert.Nil(t, values[len(data)])
+	assert.Equal(t, data[0].Data(), values[len(data)+1])
+}
+
+func TestKademliaStoreManyEmpty(t *testing.T) {
+	kads, _, done := mockNetwork(2500, 3)
+	defer done()
+
+	assert.Len(t, kads[0].StoreMany(), 0)
+	assert.Equal(t, []int{0}, kads[0].StoreMany(nil))
+	assert.Len(t, kads[0].FindMany(), 0)
+}
+
+// keyedValue is a value stored under a key of its own
+type keyedValue struct {
+	key, data []byte
+}
+
+func (v *keyedValue) Key() []byte  { return v.key }
+func (v *keyedValue) Data() []byte { return v.data }
+func (v *keyedValue) Hash() []byte { return v.key }
+
+func TestKademliaStoreManyPartial(t *testing.T) {
+	kads, _, stores, done := mockKeyedNetwork(8)
+	defer done()
+
+	assert.Nil(t, kads[0].Register("batch-owner"))
+
+	// the key of the name record, ordinary values under it are refused
+	var owned []byte
+	for _, s := range stores {
+		iter := dht.NewKademliaStore(s).Iterate(nil)
+		for iter.Next() {
+			key := iter.Item().Key()
+			if dht.NewKademliaStore(s).Kind(key) == dht.RecordKind_NAME {
+				owned = append([]byte{}, key...)
+			}
+		}
+		iter.Done()
+	}
+	if !assert.NotNil(t, owned) {
+		return
+	}
+	free := append([]byte{}, owned...)
+	free[len(free)-1] ^= 0xff
+
+	writes := kads[0].StoreMany(
+		&keyedValue{key: free, data: []byte("free value")},
+		&keyedValue{key: owned, data: []byte("squatting value")},
+	)
+	assert.Len(t, writes, 2)
+	assert.True(t, writes[0] > writes[1])
+
+	var holders int
+	for _, s := range stores {
+		if _, ok := dht.NewKademliaStore(s).Get(free); ok {
+			holders++
+		}
+	}
+	assert.Equal(t, holders, writes[0])
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/dht"
+	"github.com/zigmahq/zigma/store"
+)
+
+func TestKademliaClient(t *testing.T) {
+	kads, nodes, done := mockNetwork(1400, 8)
+	defer done()
+
+	var (
+		db     = store.NewMemoryStore()
+		node   = dht.MockNode(1500)
+		client = dht.NewKademliaClient(node, db, dht.MockRPC(node))
+	)
+	defer db.Close()
+	defer client.Stop()
+
+	for _, seed := range nodes {
+		client.Table().Update(seed)
+	}
+
+	hs := dht.String("stored by a client only node")
+	key, writes := client.Store(hs)
+	assert.Equal(t, hs.Key(), key)
+	assert.True(t, writes > 0)
+
+	b, ok := client.FindValue(hs.Key())
+	assert.True(t, ok)
+	assert.Equal(t, hs.Data(), b)
+
+	assert.True(t, kads[0].Ping(node))
+	assert.False(t, node.Equal(kads[1].FindNode(node.Hash)))
+	for _, kad := range kads {
+		assert.Equal(t, len(nodes)-1, kad.Table().Size())
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/multiformats/go-multiaddr"
+)
+
+const (
+	// the number of nodes queried concurrently by the crawler
+	crawlConcurrency = 16
+
+	// the deepest bucket of a remote node the crawler asks for
+	crawlMaxDepth = 32
+)
+
+// CrawledNode encapsulates what the crawler learned about a node
+type CrawledNode struct {
+	ID        string   `json:"id"`
+	PeerID    string   `json:"peer_id"`
+	Addrs     []string `json:"addrs"`
+	Reachable bool     `json:"reachable"`
+	Contacts  int      `json:"contacts"`
+}
+
+// CrawlResult encapsulates every node found by a crawl
+type CrawlResult struct {
+	Started   time.Time      `json:"started"`
+	Elapsed   time.Duration  `json:"elapsed"`
+	Reachable int            `json:"reachable"`
+	Nodes     []*CrawledNode `json:"nodes"`
+}
+
+// WriteJSON writes the crawl result in json format
+func (r *CrawlResult) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Crawl walks the network breadth first, starting from the seeds. Every node
+// found is asked for the contacts of its buckets with FIND_NODE requests, and
+// the crawl stops once a round of requests yields no new contacts. The routing
+// table of the local node is left untouched
+func (kad *Kademlia) Crawl(seeds ...*Node) *CrawlResult {
+	res := &CrawlResult{Started: time.Now().UTC()}
+	seen := NewContacts(kad.table.Self)
+
+	var frontier []*Node
+	for _, seed := range seeds {
+		if IsValidNode(seed) && seen.Append(seed) {
+			frontier = append(frontier, seed)
+		}
+	}
+
+	for len(frontier) > 0 {
+		var (
+			wg      sync.WaitGroup
+			sem     = make(chan struct{}, crawlConcurrency)
+			crawled = make([]*CrawledNode, len(frontier))
+			learned = make([][]*Node, len(frontier))
+		)
+		for i, node := range frontier {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, node *Node) {
+				defer func() { <-sem; wg.Done() }()
+				crawled[i], learned[i] = kad.crawlNode(node)
+			}(i, node)
+		}
+		wg.Wait()
+
+		var next []*Node
+		for i := range frontier {
+			res.Nodes = append(res.Nodes, crawled[i])
+			if crawled[i].Reachable {
+				res.Reachable++
+			}
+			for _, node := range learned[i] {
+				if IsValidNode(node) && seen.Append(node) {
+					next = append(next, node)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	res.Elapsed = time.Since(res.Started)
+	return res
+}
+
+// crawlNode asks a node for its own id first, then for a random id in each of
+// its buckets until a bucket holds fewer than k contacts; nodes that do not
+// answer the first request are reported as unreachable
+func (kad *Kademlia) crawlNode(node *Node) (*CrawledNode, []*Node) {
+	out := &CrawledNode{
+		ID:     string(node.HexString()),
+		PeerID: string(node.PeerId),
+		Addrs:  formatAddrs(node.Addrs),
+	}
+
+	closest, ok := kad.crawlQuery(node, node.Hash)
+	if !ok {
+		return out, nil
+	}
+	out.Reachable = true
+
+	contacts := NewContacts(node)
+	for _, c := range closest {
+		contacts.Append(c)
+	}
+	for idx := 0; idx < crawlMaxDepth; idx++ {
+		id := randomIDInBucket(node.Hash, idx)
+		if id == nil {
+			break
+		}
+		closest, ok := kad.crawlQuery(node, id)
+		if !ok {
+			break
+		}
+		for _, c := range closest {
+			contacts.Append(c)
+		}
+		if len(closest) < k {
+			break
+		}
+	}
+
+	out.Contacts = contacts.Len()
+	return out, contacts.Nodes()
+}
+
+// crawlQuery sends a FIND_NODE request, returns false if the node did not reply
+func (kad *Kademlia) crawlQuery(node *Node, key []byte) ([]*Node, bool) {
+	msg := compose(kad.table.Self).to(node).findNode(key)
+	rec := kad.write(msg)
+	out := <-rec(0)
+	if out == nil {
+		return nil, false
+	}
+	return out.GetClosest().GetNodes(), true
+}
+
+// formatAddrs converts binary multiaddrs to their string form, addresses which
+// cannot be decoded are hex encoded
+func formatAddrs(addrs [][]byte) []string {
+	out := make([]string, 0, len(addrs))
+	for _, b := range addrs {
+		if ma, err := multiaddr.NewMultiaddrBytes(b); err == nil {
+			out = append(out, ma.String())
+		} else {
+			out = append(out, hex.EncodeToString(b))
+		}
+	}
+	return out
+}
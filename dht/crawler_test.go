@@ -0,0 +1,69 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/dht"
+	"github.com/zigmahq/zigma/store"
+)
+
+func TestKademliaCrawl(t *testing.T) {
+	kads, nodes, done := mockNetwork(2200, 24)
+	defer done()
+
+	// a contact known by the network which never answers
+	dead := dht.MockNode(2300)
+	kads[len(kads)-1].Table().Update(dead)
+
+	var (
+		db      = store.NewMemoryStore()
+		node    = dht.MockNode(2301)
+		crawler = dht.NewKademliaClient(node, db, dht.MockRPC(node))
+	)
+	defer db.Close()
+	defer crawler.Stop()
+
+	res := crawler.Crawl(nodes[0])
+	assert.Len(t, res.Nodes, len(nodes)+1)
+	assert.Equal(t, len(nodes), res.Reachable)
+	assert.Equal(t, 0, crawler.Table().Size())
+
+	found := make(map[string]*dht.CrawledNode)
+	for _, n := range res.Nodes {
+		found[n.ID] = n
+	}
+	for _, n := range nodes {
+		c, ok := found[string(n.HexString())]
+		assert.True(t, ok)
+		assert.True(t, c.Reachable)
+		assert.True(t, c.Contacts > 0)
+	}
+	assert.False(t, found[string(dead.HexString())].Reachable)
+
+	var buf bytes.Buffer
+	assert.Nil(t, res.WriteJSON(&buf))
+
+	var o dht.CrawlResult
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &o))
+	assert.Len(t, o.Nodes, len(res.Nodes))
+	assert.Equal(t, res.Reachable, o.Reachable)
+}
@@ -0,0 +1,244 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/klauspost/reedsolomon"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/multiformats/go-multihash"
+)
+
+// the separator between the original key and the shard index when deriving
+// the key of an erasure coded shard
+var shardKeySeparator = []byte{0x2f, 0x73, 0x2f}
+
+// StoreErasure splits data into n reed-solomon shards, any m of which are
+// enough to rebuild the original value. Every shard is placed on a distinct
+// node under a derived key, and a signed manifest listing the shard keys is
+// stored under the original key, so that FindValue rebuilds the value
+// transparently. Returns the key and the number of shards placed
+func (kad *Kademlia) StoreErasure(data Hashable, m, n int) ([]byte, int) {
+	if data == nil || m < 1 || n <= m || len(data.Data()) == 0 {
+		return nil, 0
+	}
+	enc, err := reedsolomon.New(m, n-m)
+	if err != nil {
+		return nil, 0
+	}
+	shards, err := enc.Split(data.Data())
+	if err != nil {
+		return nil, 0
+	}
+	if err := enc.Encode(shards); err != nil {
+		return nil, 0
+	}
+
+	manifest := &ShardManifest{
+		Key:          data.Key(),
+		Hash:         data.Hash(),
+		Length:       uint64(len(data.Data())),
+		DataShards:   uint32(m),
+		ParityShards: uint32(n - m),
+		Shards:       make([][]byte, n),
+	}
+
+	var (
+		placed int
+		used   = NewContacts(nil)
+	)
+	for i, shard := range shards {
+		key, err := shardKey(data.Key(), i)
+		if err != nil {
+			return nil, 0
+		}
+		manifest.Shards[i] = key
+		if kad.placeShard(&hashable{key: key, data: shard, hash: key, kind: RecordKind_SHARD}, used) {
+			placed++
+		}
+	}
+	if placed < m {
+		return nil, 0
+	}
+
+	if err := kad.signManifest(manifest); err != nil {
+		return nil, 0
+	}
+	b, err := proto.Marshal(manifest)
+	if err != nil {
+		return nil, 0
+	}
+	rec := &hashable{
+		key:  data.Key(),
+		data: b,
+		hash: data.Hash(),
+		kind: RecordKind_SHARD_MANIFEST,
+	}
+	if kad.iterativeStore(rec) == 0 {
+		return nil, 0
+	}
+	return data.Key(), placed
+}
+
+// placeShard stores a shard on the closest node to the shard key which does
+// not hold another shard of the same value yet; when the network is too small
+// to keep every shard apart, the shard falls back to the closest node
+func (kad *Kademlia) placeShard(shard Hashable, used *Contacts) bool {
+	contacts := kad.iterativeFindNode(shard.Hash())
+	if contacts == nil || contacts.Len() == 0 {
+		return false
+	}
+	nodes := contacts.Nodes()
+	for _, node := range nodes {
+		if used.IndexOf(node) > -1 {
+			continue
+		}
+		if kad.storeAt(node, shard) {
+			used.Append(node)
+			return true
+		}
+	}
+	for _, node := range nodes {
+		if kad.storeAt(node, shard) {
+			return true
+		}
+	}
+	return false
+}
+
+// storeAt sends a STORE rpc to a single node and waits for the reply
+func (kad *Kademlia) storeAt(node *Node, data Hashable) bool {
+	msg := compose(kad.table.Self).to(node).store(data)
+	rec := kad.write(msg)
+	out := <-rec(0)
+	return out != nil && out.GetSuccess()
+}
+
+// rebuildErasure fetches the shards listed in a manifest in parallel, and
+// reconstructs the original value once enough shards are collected. The
+// manifest must have been published for the key it was found under
+func (kad *Kademlia) rebuildErasure(key []byte, manifest *ShardManifest) ([]byte, bool) {
+	m := int(manifest.DataShards)
+	p := int(manifest.ParityShards)
+	if !bytes.Equal(manifest.Key, key) {
+		return nil, false
+	}
+	if m < 1 || len(manifest.Shards) != m+p || !verifyManifest(manifest) {
+		return nil, false
+	}
+	enc, err := reedsolomon.New(m, p)
+	if err != nil {
+		return nil, false
+	}
+
+	var (
+		wg     sync.WaitGroup
+		shards = make([][]byte, m+p)
+	)
+	for i, key := range manifest.Shards {
+		wg.Add(1)
+		go func(i int, key []byte) {
+			defer wg.Done()
+			if p, ok := kad.iterativeFindValue(key); ok {
+				shards[i] = p.Data
+			}
+		}(i, key)
+	}
+	wg.Wait()
+
+	if err := enc.ReconstructData(shards); err != nil {
+		return nil, false
+	}
+	var buf bytes.Buffer
+	if err := enc.Join(&buf, shards, int(manifest.Length)); err != nil {
+		return nil, false
+	}
+	data := buf.Bytes()
+	if hash, err := multihash.Sum(data, h, -1); err != nil || !bytes.Equal(hash, manifest.Hash) {
+		return nil, false
+	}
+	return data, true
+}
+
+// signManifest signs the manifest with the private key of the local node
+func (kad *Kademlia) signManifest(manifest *ShardManifest) error {
+	key, err := kad.privateKey()
+	if err != nil {
+		return err
+	}
+	pub, err := crypto.MarshalPublicKey(key.GetPublic())
+	if err != nil {
+		return err
+	}
+	manifest.PublicKey = pub
+	manifest.Sig = nil
+	b, err := proto.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	sig, err := key.Sign(b)
+	if err != nil {
+		return err
+	}
+	manifest.Sig = sig
+	return nil
+}
+
+// verifyManifest checks the manifest signature against its embedded public key
+func verifyManifest(manifest *ShardManifest) bool {
+	pub, err := crypto.UnmarshalPublicKey(manifest.PublicKey)
+	if err != nil {
+		return false
+	}
+	t := *manifest
+	t.Sig = nil
+	b, err := proto.Marshal(&t)
+	if err != nil {
+		return false
+	}
+	ok, err := pub.Verify(b, manifest.Sig)
+	return err == nil && ok
+}
+
+// decodeShardManifest returns the manifest if the value is a record holding an
+// erasure coded manifest
+func decodeShardManifest(kind RecordKind, b []byte) (*ShardManifest, bool) {
+	if kind != RecordKind_SHARD_MANIFEST {
+		return nil, false
+	}
+	manifest := new(ShardManifest)
+	if err := proto.Unmarshal(b, manifest); err != nil {
+		return nil, false
+	}
+	return manifest, true
+}
+
+// shardKey derives the key of the i-th shard from the original key
+func shardKey(key []byte, i int) ([]byte, error) {
+	idx := make([]byte, 4)
+	binary.BigEndian.PutUint32(idx, uint32(i))
+
+	b := make([]byte, 0, len(key)+len(shardKeySeparator)+len(idx))
+	b = append(b, key...)
+	b = append(b, shardKeySeparator...)
+	b = append(b, idx...)
+	return multihash.Sum(b, h, -1)
+}
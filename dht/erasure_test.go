@@ -0,0 +1,139 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/dht"
+	"github.com/zigmahq/zigma/store"
+)
+
+func TestKademliaStoreErasure(t *testing.T) {
+	kads, _, done := mockNetwork(1000, 12)
+	defer done()
+
+	hs := dht.String(strings.Repeat("erasure coded value ", 512))
+	key, placed := kads[0].StoreErasure(hs, 4, 6)
+	assert.Equal(t, hs.Key(), key)
+	assert.Equal(t, 6, placed)
+
+	b, ok := kads[7].FindValue(hs.Key())
+	assert.True(t, ok)
+	assert.Equal(t, hs.Data(), b)
+}
+
+func TestKademliaStoreErasureMissingShards(t *testing.T) {
+	kads, _, stores, done := mockNetworkStores(1200, 12)
+	defer done()
+
+	hs := dht.String(strings.Repeat("erasure coded value ", 512))
+	_, placed := kads[0].StoreErasure(hs, 4, 6)
+	assert.Equal(t, 6, placed)
+
+	// any m of the n shards are enough to rebuild the value
+	manifest := findManifest(stores, hs.Key())
+	if assert.NotNil(t, manifest) {
+		for _, key := range manifest.Shards[:2] {
+			for _, s := range stores {
+				assert.Nil(t, dht.NewKademliaStore(s).Delete(key))
+			}
+		}
+	}
+
+	b, ok := kads[7].FindValue(hs.Key())
+	assert.True(t, ok)
+	assert.Equal(t, hs.Data(), b)
+}
+
+func TestKademliaStoreErasureManifestKey(t *testing.T) {
+	kads, _, stores, done := mockNetworkStores(1300, 12)
+	defer done()
+
+	hs := dht.String(strings.Repeat("erasure coded value ", 512))
+	_, placed := kads[0].StoreErasure(hs, 4, 6)
+	assert.Equal(t, 6, placed)
+
+	// a valid manifest republished under another key is not rebuilt
+	manifest := findManifest(stores, hs.Key())
+	if assert.NotNil(t, manifest) {
+		b, err := proto.Marshal(manifest)
+		assert.Nil(t, err)
+		other := dht.String("another value")
+		for _, s := range stores {
+			err := dht.NewKademliaStore(s).SetRecord(other.Key(), dht.RecordKind_SHARD_MANIFEST, b, time.Hour)
+			assert.Nil(t, err)
+		}
+		_, ok := kads[7].FindValue(other.Key())
+		assert.False(t, ok)
+	}
+}
+
+// findManifest reads the manifest of an erasure coded value from the first
+// store holding it
+func findManifest(stores []store.Store, key []byte) *dht.ShardManifest {
+	for _, s := range stores {
+		kind, b, _, ok := dht.NewKademliaStore(s).GetRecord(key)
+		if !ok || kind != dht.RecordKind_SHARD_MANIFEST {
+			continue
+		}
+		manifest := new(dht.ShardManifest)
+		if err := proto.Unmarshal(b, manifest); err == nil {
+			return manifest
+		}
+	}
+	return nil
+}
+
+func TestKademliaStoreErasureInvalidShards(t *testing.T) {
+	kads, _, done := mockNetwork(1100, 4)
+	defer done()
+
+	hs := dht.String("erasure coded value")
+	key, placed := kads[0].StoreErasure(hs, 4, 4)
+	assert.Nil(t, key)
+	assert.Zero(t, placed)
+}
+
+func TestKademliaReplicateShard(t *testing.T) {
+	kads, _, done := mockNetwork(1100, 60)
+	defer done()
+
+	// a shard pending replication in a store which is not part of the network
+	s := store.NewMemoryStore()
+	defer s.Close()
+	key := []byte("erasure coded value/s/0")
+	assert.Nil(t, store.Namespace(s, "d").Set(key, []byte("shard"), 0))
+	assert.Nil(t, store.Namespace(s, "r").Set(key, []byte{}, 0))
+
+	var pending int
+	for item := range dht.NewKademliaStore(s).PendingReplication() {
+		assert.Equal(t, key, item.Hash())
+		_, writes := kads[0].Store(item)
+		assert.True(t, writes > 0)
+		pending++
+	}
+	assert.Equal(t, 1, pending)
+
+	b, ok := kads[59].FindValue(key)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("shard"), b)
+}
@@ -16,7 +16,11 @@
 
 package dht
 
-import "github.com/multiformats/go-multihash"
+import (
+	"time"
+
+	"github.com/multiformats/go-multihash"
+)
 
 // Hashable type
 type Hashable interface {
@@ -25,10 +29,15 @@ type Hashable interface {
 	Hash() []byte
 }
 
+// hashable holds a value along with its key, hash and the kind of record it
+// holds, values read from the local store keep their expiration so that it is
+// carried forward when they are replicated
 type hashable struct {
-	key  []byte
-	data []byte
-	hash []byte
+	key     []byte
+	data    []byte
+	hash    []byte
+	kind    RecordKind
+	expires time.Time
 }
 
 func (h *hashable) Key() []byte {
@@ -17,8 +17,12 @@
 package dht
 
 import (
+	"errors"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/multiformats/go-multihash"
 	"github.com/zigmahq/zigma/store"
 )
@@ -48,12 +52,31 @@ const (
 	tRepublish = time.Hour * 24
 )
 
+// ErrNoPrivateKey is returned when a signed record is published before the
+// private key of the node is set
+var ErrNoPrivateKey = errors.New("no private key")
+
 // Kademlia represents the state of the local node in the distributed hash table
 type Kademlia struct {
-	rpc   KademliaRPC
-	stop  chan struct{}
-	store *KademliaStore
-	table *RoutingTable
+	rpc     KademliaRPC
+	key     crypto.PrivKey
+	client  bool
+	once    *sync.Once
+	ready   chan struct{}
+	stop    chan struct{}
+	store   *KademliaStore
+	table   *RoutingTable
+	metrics *lookupMetrics
+	tracer  *atomic.Value
+	scrub   *scrubber
+	paused  *sync.RWMutex
+}
+
+// BootstrapResult reports the state of the routing table after bootstrap
+type BootstrapResult struct {
+	Ready   bool          // true if at least one live contact is known
+	Size    int           // the number of contacts in the routing table
+	Elapsed time.Duration // the time taken to bootstrap
 }
 
 // KademliaReplyFn represents the wait-for-response function for KademliaRPC, passing
@@ -66,33 +89,95 @@ type KademliaRPC interface {
 	Read() <-chan *Message
 }
 
-// Bootstrap adds seed nodes to the network
-func (kad *Kademlia) Bootstrap(seeds ...*Node) {
+// Bootstrap adds seed nodes to the network, then looks up the local node id to
+// fill the nearby buckets, and refreshes every bucket with a random id in the
+// range of the bucket. The returned result reports the routing table size, and
+// the Ready channel is closed once the first bootstrap finds a live contact
+func (kad *Kademlia) Bootstrap(seeds ...*Node) BootstrapResult {
+	start := time.Now()
+
+	var wg sync.WaitGroup
 	for _, seed := range seeds {
+		wg.Add(1)
 		go func(node *Node) {
+			defer wg.Done()
 			kad.table.Update(node)
 			kad.Ping(node)
 		}(seed)
 	}
+	wg.Wait()
+
+	kad.lookupAndLearn(kad.table.Self.Hash)
+	for idx := 0; idx <= kad.table.depth(); idx++ {
+		kad.refreshBucket(idx)
+	}
+
+	res := BootstrapResult{
+		Size:    kad.table.Size(),
+		Elapsed: time.Since(start),
+	}
+	if res.Ready = res.Size > 0; res.Ready {
+		kad.once.Do(func() { close(kad.ready) })
+	}
+	return res
 }
 
-// Stop stops the kademlia server
+// Ready returns a channel that is closed once the node has been bootstrapped
+// with at least one live contact
+func (kad *Kademlia) Ready() <-chan struct{} {
+	return kad.ready
+}
+
+// Stop stops the kademlia server and its scrubber
 func (kad *Kademlia) Stop() {
+	kad.StopScrubber()
 	kad.stop <- struct{}{}
 }
 
+// Pause holds the handling of requests and the background tasks of the dht
+// until Resume is called, so that the store is not used while a backup is
+// restored into it. Pause waits for the requests and tasks in progress
+func (kad *Kademlia) Pause() {
+	kad.paused.Lock()
+}
+
+// Resume resumes the handling of requests and the background tasks held by
+// Pause
+func (kad *Kademlia) Resume() {
+	kad.paused.Unlock()
+}
+
 // Table returns the dht network routing table
 func (kad *Kademlia) Table() *RoutingTable {
 	return kad.table
 }
 
+// SetPrivateKey replaces the key used to sign records published by this node,
+// names, sets, mail and erasure manifests can't be published until a private
+// key is set
+func (kad *Kademlia) SetPrivateKey(key crypto.PrivKey) {
+	if key != nil {
+		kad.key = key
+	}
+}
+
+// privateKey returns the key records published by this node are signed with
+func (kad *Kademlia) privateKey() (crypto.PrivKey, error) {
+	if kad.key == nil {
+		return nil, ErrNoPrivateKey
+	}
+	return kad.key, nil
+}
+
 // Ping the specified contact node; returns true if pong is returned from receiver
 func (kad *Kademlia) Ping(node *Node) bool {
 	msg := compose(kad.table.Self).to(node).ping()
-	rec := kad.rpc.Write(msg)
+	rec := kad.write(msg)
 	switch out := <-rec(0); {
 	case out != nil:
-		kad.table.Update(node)
+		if !out.Client {
+			kad.table.Update(node)
+		}
 		return true
 	default:
 		kad.table.Remove(node)
@@ -124,9 +209,17 @@ func (kad *Kademlia) FindNode(key []byte) *Node {
 	return nil
 }
 
-// FindValue retrieves data from the network with a key
+// FindValue retrieves data from the network with a key; values stored with
+// StoreErasure are rebuilt from their shards
 func (kad *Kademlia) FindValue(key []byte) ([]byte, bool) {
-	return kad.iterativeFindValue(key)
+	p, ok := kad.iterativeFindValue(key)
+	if !ok {
+		return nil, false
+	}
+	if manifest, ok := decodeShardManifest(p.Kind, p.Data); ok {
+		return kad.rebuildErasure(key, manifest)
+	}
+	return p.Data, true
 }
 
 func (kad *Kademlia) iterativeStore(data Hashable) int {
@@ -141,7 +234,7 @@ func (kad *Kademlia) iterativeStore(data Hashable) int {
 	for _, node := range contacts.Nodes() {
 		go func(node *Node) {
 			msg := compose(kad.table.Self).to(node).store(data)
-			rec := kad.rpc.Write(msg)
+			rec := kad.write(msg)
 			out := <-rec(0)
 			ch <- out != nil && out.GetSuccess()
 		}(node)
@@ -160,48 +253,98 @@ func (kad *Kademlia) iterativeStore(data Hashable) int {
 }
 
 func (kad *Kademlia) iterativeFindNode(key []byte) *Contacts {
+	trace := kad.newLookupTrace(key)
+	trace.emit(TraceLookupStarted, MessageType_FIND_NODE, nil, "")
+	contacts := kad.findNode(key, trace)
+	if contacts.Len() == 0 {
+		trace.emit(TraceLookupFinished, MessageType_FIND_NODE, nil, TraceReasonNoContacts)
+	} else {
+		trace.emit(TraceLookupFinished, MessageType_FIND_NODE, nil, TraceReasonExhausted)
+	}
+	return contacts
+}
+
+func (kad *Kademlia) findNode(key []byte, trace *lookupTrace) *Contacts {
+	start := time.Now()
 	contacts := NewContacts(kad.table.Self)
 	nodes := kad.table.Kclosest(a, &Node{Hash: key})
 	if len(nodes) == 0 {
-		return nil
+		kad.metrics.observeFindNode(0, start, false)
+		return contacts
 	}
 	for _, node := range nodes {
 		msg := compose(kad.table.Self).to(node).findNode(key)
-		rec := kad.rpc.Write(msg)
+		rec := kad.write(msg)
+		trace.emit(TraceRPCSent, MessageType_FIND_NODE, node, "")
 		switch out := <-rec(0); {
 		case out != nil:
+			trace.emit(TraceRPCReply, MessageType_FIND_NODE, node, "")
 			for _, node := range out.GetClosest().GetNodes() {
-				contacts.Append(node)
+				if contacts.Append(node) {
+					trace.emit(TraceContactLearned, MessageType_FIND_NODE, node, "")
+				}
 			}
 			kad.table.Update(node)
 		default:
+			trace.emit(TraceRPCTimeout, MessageType_FIND_NODE, node, "")
 			kad.table.Remove(node)
 		}
 	}
 	contacts.Sort()
+	kad.metrics.observeFindNode(1, start, contacts.Len() > 0)
 	return contacts
 }
 
-func (kad *Kademlia) iterativeFindValue(key []byte) ([]byte, bool) {
-	contacts := kad.iterativeFindNode(key)
-	var i int
-	for {
-		if i == contacts.Len()-1 {
-			break
-		}
+func (kad *Kademlia) iterativeFindValue(key []byte) (*Payload, bool) {
+	start := time.Now()
+	trace := kad.newLookupTrace(key)
+	trace.emit(TraceLookupStarted, MessageType_FIND_VALUE, nil, "")
+
+	contacts := kad.findNode(key, trace)
+	if contacts.Len() == 0 {
+		trace.emit(TraceLookupFinished, MessageType_FIND_VALUE, nil, TraceReasonNoContacts)
+		kad.metrics.observeFindValue(0, start, false)
+		return nil, false
+	}
+
+	// the contacts found by the FIND_NODE round are queried in the second
+	// round, contacts learned from a reply in the round after the reply
+	var (
+		hops   = 1
+		rounds = make(map[string]int)
+	)
+	for i := 0; i < contacts.Len(); i++ {
 		node := contacts.Nodes()[i]
+		round, ok := rounds[string(node.Id)]
+		if !ok {
+			round = 2
+		}
+		if round > hops {
+			hops = round
+		}
 		msg := compose(kad.table.Self).to(node).findValue(key)
-		rec := kad.rpc.Write(msg)
+		rec := kad.write(msg)
+		trace.emit(TraceRPCSent, MessageType_FIND_VALUE, node, "")
 		switch out := <-rec(0); {
 		case out != nil && out.GetPayload() != nil:
-			return out.GetPayload().GetData(), true
+			trace.emit(TraceRPCReply, MessageType_FIND_VALUE, node, "")
+			trace.emit(TraceLookupFinished, MessageType_FIND_VALUE, node, TraceReasonValueFound)
+			kad.metrics.observeFindValue(round, start, true)
+			return out.GetPayload(), true
 		case out != nil && out.GetClosest() != nil:
+			trace.emit(TraceRPCReply, MessageType_FIND_VALUE, node, "")
 			for _, node := range out.GetClosest().GetNodes() {
-				contacts.Append(node)
+				if contacts.Append(node) {
+					rounds[string(node.Id)] = round + 1
+					trace.emit(TraceContactLearned, MessageType_FIND_VALUE, node, "")
+				}
 			}
+		case out == nil:
+			trace.emit(TraceRPCTimeout, MessageType_FIND_VALUE, node, "")
 		}
-		i++
 	}
+	trace.emit(TraceLookupFinished, MessageType_FIND_VALUE, nil, TraceReasonExhausted)
+	kad.metrics.observeFindValue(hops, start, false)
 	return nil, false
 }
 
@@ -214,56 +357,185 @@ func (kad *Kademlia) listen() {
 			if msg == nil || msg.IsResponse || !msg.isValid() {
 				continue
 			}
+
+			// client only nodes perform lookups and stores, but never serve
+			// requests other than PING
+			if kad.client && msg.Type != MessageType_PING {
+				continue
+			}
+
+			kad.paused.RLock()
 			switch msg.Type {
 
 			// PING RPC involves one node sending a PING message to another,
 			// which presumably replies with a PONG.
 			case MessageType_PING:
-				kad.rpc.Write(msg.pong())
+				kad.write(msg.pong())
 
 			// STORE RPC provides a key and a block of data and requires that the
 			// recipient store the data and make it available for later retrieval
 			// by that key.
 			case MessageType_STORE:
-				payload := msg.GetStore().Payload
-				kad.table.Update(msg.Sender)
-				kad.store.Set(payload.Key, payload.Data, tExpire)
-				kad.rpc.Write(msg.success(true))
+				kad.updateSender(msg)
+				kad.write(msg.success(kad.storePayload(msg.GetStore().Payload)))
 
 			// FIND_VALUE returns the associated data if corresponding value is
-			// present. Otherwise the RPC is equivalent to a FIND_NODE and a set
-			// of k triples is returned.
+			// present, or a page of the set stored under the key if the request
+			// asks for a set. Otherwise the RPC is equivalent to a FIND_NODE and
+			// a set of k triples is returned.
 			case MessageType_FIND_VALUE:
-				kad.table.Update(msg.Sender)
-				if b, ok := kad.store.Get(msg.GetFind().Key); ok {
-					kad.rpc.Write(msg.returnValue(b))
+				kad.updateSender(msg)
+				var (
+					find    = msg.GetFind()
+					kind    = RecordKind_SET_PAGE
+					b       []byte
+					expires time.Time
+					ok      bool
+				)
+				if find.Set {
+					b, ok = kad.setPage(find.Key, find.Page)
+				} else {
+					kind, b, expires, ok = kad.store.GetRecord(find.Key)
+				}
+				if ok {
+					kad.write(msg.returnValue(b, kind, expires))
 				} else {
-					nodes := kad.table.Kclosest(k, &Node{Hash: msg.GetFind().Key}, msg.Sender)
-					kad.rpc.Write(msg.returnClosest(nodes))
+					nodes := kad.table.Kclosest(k, &Node{Hash: find.Key}, msg.Sender)
+					kad.write(msg.returnClosest(nodes))
 				}
 
 			// FIND_NODE returns up to k triples for the contacts that it knows
 			// to be closest to the key
 			case MessageType_FIND_NODE:
-				kad.table.Update(msg.Sender)
+				kad.updateSender(msg)
 				nodes := kad.table.Kclosest(k, &Node{Hash: msg.GetFind().Key}, msg.Sender)
-				kad.rpc.Write(msg.returnClosest(nodes))
+				kad.write(msg.returnClosest(nodes))
+
+			// STORE_MANY is a STORE carrying a batch of payloads which share
+			// the same closest nodes, the reply tells which payloads were
+			// stored
+			case MessageType_STORE_MANY:
+				kad.updateSender(msg)
+				payloads := msg.GetStoreMany().Payloads
+				stored := make([]bool, len(payloads))
+				for i, payload := range payloads {
+					stored[i] = kad.storePayload(payload)
+				}
+				kad.write(msg.returnStored(stored))
+
+			// FIND_MANY returns the values present for a batch of keys, keys
+			// without a value are left out of the reply
+			case MessageType_FIND_MANY:
+				kad.updateSender(msg)
+				var found []*Payload
+				for _, key := range msg.GetFindMany().Keys {
+					if kind, b, expires, ok := kad.store.GetRecord(key); ok {
+						found = append(found, &Payload{Key: key, Data: b, Expires: unixNano(expires), Kind: kind})
+					}
+				}
+				kad.write(msg.returnValues(found))
 			}
+			kad.paused.RUnlock()
 		}
 	}
 }
 
+// storePayload stores a payload received in a STORE request, the kind of the
+// payload decides how it is stored. Set entries are added to their set instead
+// of overwriting the value of the key, mailbox acknowledgements remove mail,
+// name records are verified against their owner, and set pages are only ever
+// returned by lookups. Values under a registered name are refused, and values
+// keep the expiration they were published with, so that replicating a value
+// does not extend its lifetime
+func (kad *Kademlia) storePayload(payload *Payload) bool {
+	switch payload.Kind {
+	case RecordKind_SET_ENTRY:
+		entry, ok := decodeSetEntry(payload.Kind, payload.Data)
+		return ok && kad.addSetEntry(payload.Key, entry)
+	case RecordKind_MAILBOX_ACK:
+		ack, ok := decodeMailboxAck(payload.Kind, payload.Data)
+		return ok && kad.deleteMail(payload.Key, ack)
+	case RecordKind_NAME:
+		record, ok := decodeNameRecord(payload.Kind, payload.Data)
+		return ok && kad.storeName(payload.Key, record)
+	case RecordKind_SET_PAGE:
+		return false
+	}
+	if kad.nameOwned(payload.Key) {
+		return false
+	}
+	ttl, ok := payloadTTL(payload)
+	if !ok {
+		return false
+	}
+	return kad.store.SetRecord(payload.Key, payload.Kind, payload.Data, ttl) == nil
+}
+
+// write sends a message through the rpc, messages sent by a client only node
+// are flagged so that receivers leave it out of their routing tables
+func (kad *Kademlia) write(msg *Message) KademliaReplyFn {
+	msg.Client = kad.client
+	return kad.rpc.Write(msg)
+}
+
+// updateSender adds the sender of a request to the routing table, unless the
+// sender is a client only node
+func (kad *Kademlia) updateSender(msg *Message) {
+	if !msg.Client {
+		kad.table.Update(msg.Sender)
+	}
+}
+
+// lookupAndLearn looks up a key, and pings the contacts learned from the lookup
+// which are not in the routing table yet, live contacts are added to the table
+func (kad *Kademlia) lookupAndLearn(key []byte) int {
+	contacts := kad.iterativeFindNode(key)
+
+	var wg sync.WaitGroup
+	for _, node := range contacts.Nodes() {
+		if node.Equal(kad.table.Self) || kad.table.Contains(node) {
+			continue
+		}
+		wg.Add(1)
+		go func(node *Node) {
+			defer wg.Done()
+			kad.Ping(node)
+		}(node)
+	}
+	wg.Wait()
+	return contacts.Len()
+}
+
+// refreshBucket looks up a random id in the range of the bucket
+func (kad *Kademlia) refreshBucket(idx int) {
+	if id := kad.table.RandomIDFromBucket(idx); id != nil {
+		if kad.lookupAndLearn(id) > 0 {
+			kad.table.MarkBucketRefreshed(idx)
+		}
+	}
+}
+
+// refreshBuckets refreshes the stale buckets up to the deepest non-empty
+// bucket, buckets beyond that share their closest contacts with the local node
 func (kad *Kademlia) refreshBuckets() {
+	var (
+		depth = kad.table.depth()
+		stale []int
+	)
 	for idx := range kad.table.BucketsNeededForRefresh() {
-		if node := kad.table.RandomNodeFromBucket(idx); node != nil {
-			if kad.iterativeFindNode(node.Id).Len() > 0 {
-				kad.table.MarkBucketRefreshed(idx)
-			}
+		if idx <= depth {
+			stale = append(stale, idx)
 		}
 	}
+	for _, idx := range stale {
+		kad.refreshBucket(idx)
+	}
 }
 
 func (kad *Kademlia) replicaDatabase() {
+	kad.paused.RLock()
+	defer kad.paused.RUnlock()
+
 	for hashable := range kad.store.PendingReplication() {
 		kad.Store(hashable)
 	}
@@ -285,14 +557,31 @@ func (kad *Kademlia) scheduleTasks() {
 
 // NewKademlia initializes a DHT kademlia service
 func NewKademlia(self *Node, store store.Store, rpc KademliaRPC) *Kademlia {
+	return newKademlia(self, store, rpc, false)
+}
+
+// NewKademliaClient initializes a client only DHT kademlia service, which
+// performs lookups and stores, but is never advertised as a routing contact
+func NewKademliaClient(self *Node, store store.Store, rpc KademliaRPC) *Kademlia {
+	return newKademlia(self, store, rpc, true)
+}
+
+func newKademlia(self *Node, store store.Store, rpc KademliaRPC, client bool) *Kademlia {
 	s := make(chan struct{})
 	t := NewRoutingTable(self)
 	r := NewKademliaStore(store)
 	k := &Kademlia{
-		rpc:   rpc,
-		stop:  s,
-		store: r,
-		table: t,
+		rpc:     rpc,
+		client:  client,
+		once:    new(sync.Once),
+		ready:   make(chan struct{}),
+		stop:    s,
+		store:   r,
+		table:   t,
+		metrics: newLookupMetrics(),
+		tracer:  new(atomic.Value),
+		scrub:   new(scrubber),
+		paused:  new(sync.RWMutex),
 	}
 	go k.listen()
 	go k.scheduleTasks()
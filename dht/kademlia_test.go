@@ -17,10 +17,15 @@
 package dht_test
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"testing"
+	"time"
 
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/stretchr/testify/assert"
 	"github.com/zigmahq/zigma/dht"
 	"github.com/zigmahq/zigma/log"
@@ -48,10 +53,81 @@ func done() {
 	}
 }
 
+// mockNetwork starts l kademlia nodes that know every other node, seed offsets
+// the mock node ids so that separate networks do not share node ids
+func mockNetwork(seed, l int) ([]*dht.Kademlia, []*dht.Node, func()) {
+	kads, nodes, _, done := mockNetworkStores(seed, l)
+	return kads, nodes, done
+}
+
+// mockNetworkStores is mockNetwork, along with the stores of the nodes
+func mockNetworkStores(seed, l int) ([]*dht.Kademlia, []*dht.Node, []store.Store, func()) {
+	nodes := make([]*dht.Node, l)
+	for i := 0; i < l; i++ {
+		nodes[i] = dht.MockNode(seed + i)
+	}
+	return mockNetworkOf(nodes, mockKeys(l))
+}
+
+// mockKeyedNetwork starts l kademlia nodes whose peer ids are derived from the
+// keys of the nodes, as the nodes of a p2p server are
+func mockKeyedNetwork(l int) ([]*dht.Kademlia, []*dht.Node, []store.Store, func()) {
+	keys := mockKeys(l)
+	nodes := make([]*dht.Node, l)
+	for i, key := range keys {
+		pid, err := peer.IDFromPrivateKey(key)
+		if err != nil {
+			panic(err)
+		}
+		nodes[i] = dht.NodeFromPeerID(pid)
+	}
+	return mockNetworkOf(nodes, keys)
+}
+
+// mockKeys generates the ed25519 keys of l nodes
+func mockKeys(l int) []crypto.PrivKey {
+	keys := make([]crypto.PrivKey, l)
+	for i := range keys {
+		key, _, err := crypto.GenerateKeyPair(crypto.Ed25519, -1)
+		if err != nil {
+			panic(err)
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+// mockNetworkOf starts a kademlia node for every node, signing with its key
+func mockNetworkOf(nodes []*dht.Node, keys []crypto.PrivKey) ([]*dht.Kademlia, []*dht.Node, []store.Store, func()) {
+	var (
+		l      = len(nodes)
+		kads   = make([]*dht.Kademlia, l)
+		stores = make([]store.Store, l)
+	)
+	for i := 0; i < l; i++ {
+		stores[i] = store.NewMemoryStore()
+		kads[i] = dht.NewKademlia(nodes[i], stores[i], dht.MockRPC(nodes[i], i == 0))
+		kads[i].SetPrivateKey(keys[i])
+	}
+	for i := 0; i < l; i++ {
+		for j := 0; j < l; j++ {
+			if i != j {
+				kads[i].Table().Update(nodes[j])
+			}
+		}
+	}
+	return kads, nodes, stores, func() {
+		for i := 0; i < l; i++ {
+			stores[i].Close()
+			kads[i].Stop()
+		}
+	}
+}
+
 func TestNewKademlia(t *testing.T) {
 	for i := 0; i < n; i++ {
 		var (
-			db   = store.TempBadgerStore()
+			db   = store.NewMemoryStore()
 			node = dht.MockNode(i)
 			rpc  = dht.MockRPC(node, i == 0)
 			kad  = dht.NewKademlia(node, db, rpc)
@@ -68,13 +144,16 @@ func TestNewKademlia(t *testing.T) {
 	}
 	for i := 0; i < n; i++ {
 		kad := kadList[i]
+		seeds := make([]*dht.Node, 0, n-1)
 		for j := 0; j < n; j++ {
 			if j == i {
 				continue
 			}
-			node := nodeList[j]
-			kad.Bootstrap(node)
+			seeds = append(seeds, nodeList[j])
 		}
+		res := kad.Bootstrap(seeds...)
+		assert.True(t, res.Ready)
+		assert.Equal(t, kad.Table().Size(), res.Size)
 	}
 }
 
@@ -114,3 +193,133 @@ func TestKademliaFindNode(t *testing.T) {
 	node := kadList[0].FindNode(hs.Hash())
 	assert.NotNil(t, node)
 }
+
+func TestKademliaBootstrap(t *testing.T) {
+	kads, nodes, done := mockNetwork(1600, 30)
+	defer done()
+
+	var (
+		db   = store.NewMemoryStore()
+		node = dht.MockNode(1700)
+		kad  = dht.NewKademlia(node, db, dht.MockRPC(node))
+	)
+	defer db.Close()
+	defer kad.Stop()
+
+	select {
+	case <-kad.Ready():
+		t.Fatal("node is ready before bootstrap")
+	default:
+	}
+
+	res := kad.Bootstrap(nodes[0])
+	assert.True(t, res.Ready)
+	assert.True(t, res.Size > 1)
+	assert.Equal(t, res.Size, kad.Table().Size())
+	<-kad.Ready()
+
+	hs := dht.String("stored right after bootstrap")
+	_, writes := kad.Store(hs)
+	assert.True(t, writes > 0)
+
+	b, ok := kads[len(kads)-1].FindValue(hs.Key())
+	assert.True(t, ok)
+	assert.Equal(t, hs.Data(), b)
+}
+
+func TestKademliaBootstrapWithoutSeeds(t *testing.T) {
+	var (
+		db   = store.NewMemoryStore()
+		node = dht.MockNode(1800)
+		kad  = dht.NewKademlia(node, db, dht.MockRPC(node, true))
+	)
+	defer db.Close()
+	defer kad.Stop()
+
+	res := kad.Bootstrap()
+	assert.False(t, res.Ready)
+	assert.Zero(t, res.Size)
+
+	b, ok := kad.FindValue(dht.String("nothing").Hash())
+	assert.False(t, ok)
+	assert.Nil(t, b)
+}
+
+func TestMigrateStore(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	assert.Nil(t, dht.MigrateStore(db))
+	v, ok, err := store.SchemaVersion(db, "dht")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), v)
+
+	// a database written by a newer format is refused
+	assert.Nil(t, store.Namespace(db, "schema").Set([]byte("dht"), []byte{0x7f}, 0))
+	assert.Equal(t, store.ErrSchemaTooNew, dht.MigrateStore(db))
+}
+
+func TestKademliaStoreGetWithTTL(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	s := dht.NewKademliaStore(db)
+	k := []byte("ttl")
+	assert.Nil(t, s.Set(k, k, time.Hour))
+
+	v, expires, ok := s.GetWithTTL(k)
+	assert.True(t, ok)
+	assert.Equal(t, k, v)
+	assert.True(t, time.Until(expires) > time.Hour-time.Minute)
+	assert.True(t, time.Until(expires) <= time.Hour)
+
+	iter := s.Iterate(nil)
+	assert.True(t, iter.Next())
+	assert.Equal(t, expires, iter.Item().TTL())
+	iter.Done()
+}
+
+func TestKademliaStoreMethods(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	s := dht.NewKademliaStore(db)
+	k := []byte("versioned")
+	assert.Nil(t, s.CompareAndSet(k, 0, []byte("v1"), 0))
+	v, version, ok := s.GetVersioned(k)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("v1"), v)
+	assert.Nil(t, s.CompareAndSet(k, version, []byte("v2"), 0))
+	assert.Equal(t, store.ErrVersionMismatch, s.CompareAndSet(k, version, []byte("v3"), 0))
+
+	u := []byte("updated")
+	assert.Nil(t, s.Update(func(txn store.Txn) error {
+		return txn.Set(u, u, 0)
+	}))
+
+	// the values are kept in the namespace of the values, never in the root
+	for _, key := range [][]byte{k, u} {
+		_, ok := db.Get(key)
+		assert.False(t, ok)
+		_, ok = s.Get(key)
+		assert.True(t, ok)
+		_, ok = store.Namespace(db, "r").Get(key)
+		assert.True(t, ok)
+	}
+
+	_, err := s.Backup(ioutil.Discard, 0)
+	assert.Equal(t, store.ErrNamespaceBackup, err)
+	assert.Equal(t, store.ErrNamespaceBackup, s.Restore(bytes.NewReader(nil)))
+}
+
+func TestKademliaPause(t *testing.T) {
+	kads, nodes, done := mockNetwork(3900, 3)
+	defer done()
+
+	// a paused node holds the requests it receives until it resumes
+	kads[1].Pause()
+	assert.False(t, kads[0].Ping(nodes[1]))
+	kads[1].Resume()
+	assert.True(t, kads[0].Ping(nodes[1]))
+}
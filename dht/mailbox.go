@@ -0,0 +1,396 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/multiformats/go-multihash"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// the suffix appended to the public key of a recipient when deriving the key
+// of its mailbox
+var mailboxSuffix = []byte{0x2f, 0x6d, 0x62, 0x6f, 0x78}
+
+// the prime of curve25519, 2^255 - 19
+var curveP = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// Errors for the mailbox
+var (
+	ErrMailboxKeyType = errors.New("mailbox requires an ed25519 key")
+	ErrMailNotStored  = errors.New("mail not stored")
+	ErrMailUnreadable = errors.New("mail cannot be decrypted")
+)
+
+// Mail encapsulates a message read from the mailbox of the local node
+type Mail struct {
+	ID   []byte
+	From crypto.PubKey
+	Data []byte
+	Sent time.Time
+}
+
+// SendMail leaves a message in the mailbox of a peer which may be offline. The
+// message is signed by the local node, encrypted to the ed25519 key of the
+// recipient, and kept in the dht until the ttl expires or the recipient
+// acknowledges it. Returns the number of nodes holding the message
+func (kad *Kademlia) SendMail(to crypto.PubKey, data []byte, ttl time.Duration) (int, error) {
+	mailbox, err := mailboxKey(to)
+	if err != nil {
+		return 0, err
+	}
+	recipient, err := Curve25519PublicKey(to)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := kad.privateKey()
+	if err != nil {
+		return 0, err
+	}
+	env, err := signMail(key, to, data)
+	if err != nil {
+		return 0, err
+	}
+	b, err := proto.Marshal(env)
+	if err != nil {
+		return 0, err
+	}
+	sealed, err := sealMail(b, recipient)
+	if err != nil {
+		return 0, err
+	}
+
+	// every message is published under its own ephemeral key, which becomes
+	// the id of the message, so that messages of a sender do not replace each
+	// other in the mailbox
+	id, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return 0, err
+	}
+	writes := kad.addToSet(id, mailbox, sealed, ttl)
+	if writes == 0 {
+		return 0, ErrMailNotStored
+	}
+	return writes, nil
+}
+
+// FetchMail polls the mailbox of the local node, and returns the messages which
+// can be decrypted and carry a valid sender signature
+func (kad *Kademlia) FetchMail() ([]*Mail, error) {
+	key, err := kad.privateKey()
+	if err != nil {
+		return nil, err
+	}
+	mailbox, err := mailboxKey(key.GetPublic())
+	if err != nil {
+		return nil, err
+	}
+	priv, err := Curve25519PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	entries, ok := kad.FindSet(mailbox)
+	if !ok {
+		return nil, nil
+	}
+
+	var mails []*Mail
+	for _, entry := range entries {
+		mail, err := openMail(key.GetPublic(), entry, priv)
+		if err != nil {
+			continue
+		}
+		mails = append(mails, mail)
+	}
+	return mails, nil
+}
+
+// ReadMail polls the mailbox of the local node and passes every message to fn,
+// the messages fn accepts are acknowledged while a message fn fails on is kept
+// in the mailbox to be read again. Returns the number of messages accepted
+func (kad *Kademlia) ReadMail(fn func(*Mail) error) (int, error) {
+	mails, err := kad.FetchMail()
+	if err != nil {
+		return 0, err
+	}
+	var ids [][]byte
+	for _, mail := range mails {
+		if fn(mail) == nil {
+			ids = append(ids, mail.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if writes, err := kad.AckMail(ids...); err != nil || writes == 0 {
+		return 0, ErrMailNotStored
+	}
+	return len(ids), nil
+}
+
+// AckMail acknowledges messages read from the mailbox of the local node, the
+// nodes holding the messages delete them. Returns the number of nodes which
+// accepted the acknowledgement
+func (kad *Kademlia) AckMail(ids ...[]byte) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	key, err := kad.privateKey()
+	if err != nil {
+		return 0, err
+	}
+	mailbox, err := mailboxKey(key.GetPublic())
+	if err != nil {
+		return 0, err
+	}
+	pub, err := crypto.MarshalPublicKey(key.GetPublic())
+	if err != nil {
+		return 0, err
+	}
+	ack := &MailboxAck{Mailbox: mailbox, Ids: ids, PublicKey: pub}
+	b, err := proto.Marshal(ack)
+	if err != nil {
+		return 0, err
+	}
+	if ack.Sig, err = key.Sign(b); err != nil {
+		return 0, err
+	}
+	if b, err = proto.Marshal(ack); err != nil {
+		return 0, err
+	}
+	writes := kad.iterativeStore(&hashable{
+		key:  mailbox,
+		data: b,
+		hash: mailbox,
+		kind: RecordKind_MAILBOX_ACK,
+	})
+	return writes, nil
+}
+
+// deleteMail verifies an acknowledgement received in a STORE request, and
+// removes the acknowledged messages; only the owner of a mailbox is allowed
+// to acknowledge its messages
+func (kad *Kademlia) deleteMail(mailbox []byte, ack *MailboxAck) bool {
+	pub, err := crypto.UnmarshalPublicKey(ack.PublicKey)
+	if err != nil {
+		return false
+	}
+	owner, err := mailboxKey(pub)
+	if err != nil || !bytes.Equal(owner, mailbox) || !bytes.Equal(ack.Mailbox, mailbox) {
+		return false
+	}
+	t := *ack
+	t.Sig = nil
+	b, err := proto.Marshal(&t)
+	if err != nil {
+		return false
+	}
+	if ok, err := pub.Verify(b, ack.Sig); err != nil || !ok {
+		return false
+	}
+	for _, id := range ack.Ids {
+		if err := kad.store.DeleteSetEntry(mailbox, id); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// signMail composes a message envelope signed with the key of the local node
+func signMail(key crypto.PrivKey, to crypto.PubKey, data []byte) (*MailEnvelope, error) {
+	from, err := crypto.MarshalPublicKey(key.GetPublic())
+	if err != nil {
+		return nil, err
+	}
+	rcpt, err := crypto.MarshalPublicKey(to)
+	if err != nil {
+		return nil, err
+	}
+	env := &MailEnvelope{
+		From: from,
+		To:   rcpt,
+		Data: data,
+		Sent: time.Now().UnixNano(),
+	}
+	b, err := proto.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	if env.Sig, err = key.Sign(b); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// openMail decrypts a mailbox entry, and checks the sender signature and that
+// the message was addressed to the key of the local node
+func openMail(to crypto.PubKey, entry *SetEntry, priv *[32]byte) (*Mail, error) {
+	b, ok := openSealedMail(entry.Data, priv)
+	if !ok {
+		return nil, ErrMailUnreadable
+	}
+	env := new(MailEnvelope)
+	if err := proto.Unmarshal(b, env); err != nil {
+		return nil, ErrMailUnreadable
+	}
+	self, err := crypto.MarshalPublicKey(to)
+	if err != nil || !bytes.Equal(env.To, self) {
+		return nil, ErrMailUnreadable
+	}
+	from, err := crypto.UnmarshalPublicKey(env.From)
+	if err != nil {
+		return nil, ErrMailUnreadable
+	}
+	t := *env
+	t.Sig = nil
+	if b, err = proto.Marshal(&t); err != nil {
+		return nil, ErrMailUnreadable
+	}
+	if ok, err := from.Verify(b, env.Sig); err != nil || !ok {
+		return nil, ErrMailUnreadable
+	}
+	return &Mail{
+		ID:   entry.PublicKey,
+		From: from,
+		Data: env.Data,
+		Sent: time.Unix(0, env.Sent),
+	}, nil
+}
+
+// decodeMailboxAck returns the acknowledgement if the value is a record holding
+// a mailbox acknowledgement
+func decodeMailboxAck(kind RecordKind, b []byte) (*MailboxAck, bool) {
+	if kind != RecordKind_MAILBOX_ACK {
+		return nil, false
+	}
+	ack := new(MailboxAck)
+	if err := proto.Unmarshal(b, ack); err != nil {
+		return nil, false
+	}
+	return ack, true
+}
+
+// mailboxKey derives the dht key of the mailbox of a recipient
+func mailboxKey(pub crypto.PubKey) ([]byte, error) {
+	b, err := crypto.MarshalPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return multihash.Sum(append(b, mailboxSuffix...), h, -1)
+}
+
+// sealMail encrypts a message to a curve25519 key with an ephemeral key pair
+func sealMail(msg []byte, recipient *[32]byte) ([]byte, error) {
+	pub, priv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	sealed := &SealedMail{
+		Ephemeral: pub[:],
+		Nonce:     nonce[:],
+		Box:       box.Seal(nil, msg, &nonce, recipient, priv),
+	}
+	return proto.Marshal(sealed)
+}
+
+// openSealedMail decrypts a message sealed with sealMail
+func openSealedMail(b []byte, priv *[32]byte) ([]byte, bool) {
+	sealed := new(SealedMail)
+	if err := proto.Unmarshal(b, sealed); err != nil {
+		return nil, false
+	}
+	if len(sealed.Ephemeral) != 32 || len(sealed.Nonce) != 24 {
+		return nil, false
+	}
+	var (
+		pub   [32]byte
+		nonce [24]byte
+	)
+	copy(pub[:], sealed.Ephemeral)
+	copy(nonce[:], sealed.Nonce)
+	return box.Open(nil, sealed.Box, &nonce, &pub, priv)
+}
+
+// Curve25519PublicKey converts an ed25519 public key to its curve25519 form,
+// the montgomery u = (1 + y) / (1 - y) of the edwards point, as computed by
+// crypto_sign_ed25519_pk_to_curve25519 of libsodium
+func Curve25519PublicKey(pub crypto.PubKey) (*[32]byte, error) {
+	if _, ok := pub.(*crypto.Ed25519PublicKey); !ok {
+		return nil, ErrMailboxKeyType
+	}
+	raw, err := pub.Raw()
+	if err != nil || len(raw) != 32 {
+		return nil, ErrMailboxKeyType
+	}
+
+	le := make([]byte, 32)
+	for i := range raw {
+		le[31-i] = raw[i]
+	}
+	le[0] &= 0x7f
+	y := new(big.Int).SetBytes(le)
+
+	one := big.NewInt(1)
+	num := new(big.Int).Add(one, y)
+	den := new(big.Int).Sub(one, y)
+	den.Mod(den, curveP)
+	inv := new(big.Int).ModInverse(den, curveP)
+	if inv == nil {
+		return nil, ErrMailboxKeyType
+	}
+	u := num.Mul(num, inv).Mod(num, curveP).Bytes()
+
+	var out [32]byte
+	for i := range u {
+		out[i] = u[len(u)-1-i]
+	}
+	return &out, nil
+}
+
+// Curve25519PrivateKey converts an ed25519 private key to its curve25519 form,
+// the clamped hash of the seed, as computed by
+// crypto_sign_ed25519_sk_to_curve25519 of libsodium
+func Curve25519PrivateKey(priv crypto.PrivKey) (*[32]byte, error) {
+	if _, ok := priv.(*crypto.Ed25519PrivateKey); !ok {
+		return nil, ErrMailboxKeyType
+	}
+	raw, err := priv.Raw()
+	if err != nil || len(raw) < 32 {
+		return nil, ErrMailboxKeyType
+	}
+	digest := sha512.Sum512(raw[:32])
+	digest[0] &= 248
+	digest[31] &= 127
+	digest[31] |= 64
+
+	var out [32]byte
+	copy(out[:], digest[:32])
+	return &out, nil
+}
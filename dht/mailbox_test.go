@@ -0,0 +1,162 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht_test
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/dht"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestKademliaMailbox(t *testing.T) {
+	kads, _, done := mockNetwork(2900, 6)
+	defer done()
+
+	var keys []crypto.PrivKey
+	for _, kad := range kads {
+		key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+		assert.Nil(t, err)
+		kad.SetPrivateKey(key)
+		keys = append(keys, key)
+	}
+	recipient := keys[4].GetPublic()
+
+	for _, msg := range []string{"first message", "second message"} {
+		writes, err := kads[1].SendMail(recipient, []byte(msg), time.Minute)
+		assert.Nil(t, err)
+		assert.True(t, writes > 0)
+	}
+
+	mails, err := kads[2].FetchMail()
+	assert.Nil(t, err)
+	assert.Len(t, mails, 0)
+
+	mails, err = kads[4].FetchMail()
+	assert.Nil(t, err)
+	assert.Len(t, mails, 2)
+
+	var (
+		data []string
+		ids  [][]byte
+	)
+	for _, mail := range mails {
+		assert.True(t, mail.From.Equals(keys[1].GetPublic()))
+		assert.False(t, mail.Sent.IsZero())
+		data = append(data, string(mail.Data))
+		ids = append(ids, mail.ID)
+	}
+	sort.Strings(data)
+	assert.Equal(t, []string{"first message", "second message"}, data)
+
+	// an acknowledgement only applies to the mailbox of its signer
+	_, err = kads[2].AckMail(ids...)
+	assert.Nil(t, err)
+	mails, err = kads[4].FetchMail()
+	assert.Nil(t, err)
+	assert.Len(t, mails, 2)
+
+	writes, err := kads[4].AckMail(ids[0])
+	assert.Nil(t, err)
+	assert.True(t, writes > 0)
+
+	mails, err = kads[4].FetchMail()
+	assert.Nil(t, err)
+	assert.Len(t, mails, 1)
+	assert.Equal(t, ids[1], mails[0].ID)
+}
+
+func TestKademliaMailboxKeyType(t *testing.T) {
+	kads, _, done := mockNetwork(3000, 3)
+	defer done()
+
+	_, pub, err := crypto.GenerateSecp256k1Key(rand.Reader)
+	assert.Nil(t, err)
+
+	_, err = kads[0].SendMail(pub, []byte("unsupported"), time.Minute)
+	assert.Equal(t, dht.ErrMailboxKeyType, err)
+}
+
+func TestKademliaReadMail(t *testing.T) {
+	kads, _, done := mockNetwork(3600, 4)
+	defer done()
+
+	var keys []crypto.PrivKey
+	for _, kad := range kads {
+		key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+		assert.Nil(t, err)
+		kad.SetPrivateKey(key)
+		keys = append(keys, key)
+	}
+	for _, msg := range []string{"handled", "failed"} {
+		_, err := kads[0].SendMail(keys[3].GetPublic(), []byte(msg), time.Minute)
+		assert.Nil(t, err)
+	}
+
+	// only the messages which were handled are acknowledged
+	read, err := kads[3].ReadMail(func(mail *dht.Mail) error {
+		if string(mail.Data) == "failed" {
+			return errors.New("not handled")
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, read)
+
+	mails, err := kads[3].FetchMail()
+	assert.Nil(t, err)
+	if assert.Len(t, mails, 1) {
+		assert.Equal(t, []byte("failed"), mails[0].Data)
+	}
+}
+
+func TestCurve25519Keys(t *testing.T) {
+	// the key conversion vector of libsodium
+	seed, _ := hex.DecodeString("421151a459faeade3d247115f94aedae42318124095afabe4d1451a559faedee")
+	key, err := crypto.UnmarshalEd25519PrivateKey(ed25519.NewKeyFromSeed(seed))
+	assert.Nil(t, err)
+
+	pub, err := dht.Curve25519PublicKey(key.GetPublic())
+	assert.Nil(t, err)
+	assert.Equal(t, "f1814f0e8ff1043d8a44d25babff3cedcae6c22c3edaa48f857ae70de2baae50", hex.EncodeToString(pub[:]))
+
+	priv, err := dht.Curve25519PrivateKey(key)
+	assert.Nil(t, err)
+	assert.Equal(t, "8052030376d47112be7f73ed7a019293dd12ad910b654455798b4667d73de166", hex.EncodeToString(priv[:]))
+
+	// the converted public key is the public key of the converted private key
+	for i := 0; i < 32; i++ {
+		key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+		assert.Nil(t, err)
+		pub, err := dht.Curve25519PublicKey(key.GetPublic())
+		assert.Nil(t, err)
+		priv, err := dht.Curve25519PrivateKey(key)
+		assert.Nil(t, err)
+
+		var expected [32]byte
+		curve25519.ScalarBaseMult(&expected, priv)
+		assert.Equal(t, expected, *pub)
+	}
+}
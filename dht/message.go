@@ -16,7 +16,11 @@
 
 package dht
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 func compose(sender *Node) *Message {
 	uid, err := uuid.NewRandom()
@@ -49,16 +53,26 @@ func (m *Message) store(payload Hashable) *Message {
 	m.Type = MessageType_STORE
 	m.Request = &Message_Store{
 		Store: &StoreRequest{
-			Payload: &Payload{
-				Key:  payload.Key(),
-				Data: payload.Data(),
-				Hash: payload.Hash(),
-			},
+			Payload: newPayload(payload),
 		},
 	}
 	return m
 }
 
+func (m *Message) storeMany(payloads []Hashable) *Message {
+	req := &StoreManyRequest{
+		Payloads: make([]*Payload, 0, len(payloads)),
+	}
+	for _, payload := range payloads {
+		req.Payloads = append(req.Payloads, newPayload(payload))
+	}
+	m.Type = MessageType_STORE_MANY
+	m.Request = &Message_StoreMany{
+		StoreMany: req,
+	}
+	return m
+}
+
 func (m *Message) success(success bool) *Message {
 	var n = new(Message)
 	*n = *m
@@ -107,7 +121,19 @@ func (m *Message) findValue(id []byte) *Message {
 	return m
 }
 
-func (m *Message) returnValue(data []byte) *Message {
+func (m *Message) findSetPage(id []byte, page uint32) *Message {
+	m.Type = MessageType_FIND_VALUE
+	m.Request = &Message_Find{
+		Find: &FindRequest{
+			Key:  id,
+			Page: page,
+			Set:  true,
+		},
+	}
+	return m
+}
+
+func (m *Message) returnValue(data []byte, kind RecordKind, expires time.Time) *Message {
 	var n = new(Message)
 	*n = *m
 
@@ -116,15 +142,97 @@ func (m *Message) returnValue(data []byte) *Message {
 	n.Request = nil
 	n.Response = &Message_Payload{
 		Payload: &Payload{
-			Key:  m.GetFind().Key,
-			Data: data,
-			Hash: nil,
-			Sig:  nil,
+			Key:     m.GetFind().Key,
+			Data:    data,
+			Hash:    nil,
+			Sig:     nil,
+			Expires: unixNano(expires),
+			Kind:    kind,
 		},
 	}
 	return n
 }
 
+func (m *Message) findMany(ids [][]byte) *Message {
+	m.Type = MessageType_FIND_MANY
+	m.Request = &Message_FindMany{
+		FindMany: &FindManyRequest{
+			Keys: ids,
+		},
+	}
+	return m
+}
+
+func (m *Message) returnValues(payloads []*Payload) *Message {
+	var n = new(Message)
+	*n = *m
+
+	n.IsResponse = true
+	n.Sender, n.Receiver = n.Receiver, n.Sender
+	n.Request = nil
+	n.Response = &Message_Values{
+		Values: &Payloads{
+			Payloads: payloads,
+		},
+	}
+	return n
+}
+
+func (m *Message) returnStored(stored []bool) *Message {
+	var n = new(Message)
+	*n = *m
+
+	n.IsResponse = true
+	n.Sender, n.Receiver = n.Receiver, n.Sender
+	n.Request = nil
+	n.Response = &Message_Stored{
+		Stored: &StoreManyResults{
+			Stored: stored,
+		},
+	}
+	return n
+}
+
+// newPayload composes the payload of a value, along with the kind of record it
+// holds and its expiration if the value was read from the local store
+func newPayload(data Hashable) *Payload {
+	p := &Payload{
+		Key:  data.Key(),
+		Data: data.Data(),
+		Hash: data.Hash(),
+	}
+	if h, ok := data.(*hashable); ok {
+		p.Kind = h.kind
+		p.Expires = unixNano(h.expires)
+	}
+	return p
+}
+
+// unixNano converts an expiration to the unix nanoseconds carried by payloads,
+// 0 for no expiration
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// payloadTTL returns the lifetime left to a payload, capped by the expiration
+// of ordinary values; returns false if the payload has already expired
+func payloadTTL(p *Payload) (time.Duration, bool) {
+	if p.Expires == 0 {
+		return tExpire, true
+	}
+	ttl := time.Until(time.Unix(0, p.Expires))
+	if ttl <= 0 {
+		return 0, false
+	}
+	if ttl > tExpire {
+		ttl = tExpire
+	}
+	return ttl, true
+}
+
 func (m *Message) to(receiver *Node) *Message {
 	m.Receiver = receiver
 	return m
@@ -149,6 +257,20 @@ func (m *Message) isValid() bool {
 			payload := m.GetStore().Payload
 			return len(payload.Key) > 0 && len(payload.Data) > 0
 		}
+
+	case m.Type == MessageType_STORE_MANY:
+		if m.GetStoreMany() == nil || len(m.GetStoreMany().Payloads) == 0 {
+			return false
+		}
+		for _, payload := range m.GetStoreMany().Payloads {
+			if payload == nil || len(payload.Key) == 0 || len(payload.Data) == 0 {
+				return false
+			}
+		}
+		return true
+
+	case m.Type == MessageType_FIND_MANY:
+		return m.GetFindMany() != nil && len(m.GetFindMany().Keys) > 0
 	}
 	return false
 }
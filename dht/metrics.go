@@ -0,0 +1,134 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	// upper bounds of the lookup hop histogram buckets, a hop is a round of
+	// queries sent to the contacts learned in the previous round
+	hopBounds = []float64{1, 2, 3, 4, 6, 8, 12, 16, 24, 32}
+
+	// upper bounds of the lookup latency histogram buckets, in milliseconds
+	latencyBounds = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+)
+
+// Histogram counts observations into buckets with the given upper bounds, the
+// last count holds the observations larger than every bound
+type Histogram struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []uint64  `json:"counts"`
+	Count  uint64    `json:"count"`
+	Sum    float64   `json:"sum"`
+}
+
+func (h *Histogram) observe(v float64) {
+	i := 0
+	for ; i < len(h.Bounds); i++ {
+		if v <= h.Bounds[i] {
+			break
+		}
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += v
+}
+
+func (h *Histogram) copy() Histogram {
+	o := Histogram{
+		Bounds: make([]float64, len(h.Bounds)),
+		Counts: make([]uint64, len(h.Counts)),
+		Count:  h.Count,
+		Sum:    h.Sum,
+	}
+	copy(o.Bounds, h.Bounds)
+	copy(o.Counts, h.Counts)
+	return o
+}
+
+func newHistogram(bounds []float64) Histogram {
+	return Histogram{
+		Bounds: bounds,
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// LookupStats encapsulates the counters of a kind of lookup
+type LookupStats struct {
+	Total   uint64    `json:"total"`
+	Success uint64    `json:"success"`
+	Hops    Histogram `json:"hops"`
+	Latency Histogram `json:"latency_ms"`
+}
+
+type lookupMetrics struct {
+	mutex     *sync.Mutex
+	findNode  LookupStats
+	findValue LookupStats
+}
+
+func (m *lookupMetrics) observe(s *LookupStats, hops int, start time.Time, success bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	s.Total++
+	if success {
+		s.Success++
+	}
+	s.Hops.observe(float64(hops))
+	s.Latency.observe(float64(time.Since(start)) / float64(time.Millisecond))
+}
+
+func (m *lookupMetrics) observeFindNode(hops int, start time.Time, success bool) {
+	m.observe(&m.findNode, hops, start, success)
+}
+
+func (m *lookupMetrics) observeFindValue(hops int, start time.Time, success bool) {
+	m.observe(&m.findValue, hops, start, success)
+}
+
+func (m *lookupMetrics) copy() (LookupStats, LookupStats) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	cp := func(s LookupStats) LookupStats {
+		return LookupStats{
+			Total:   s.Total,
+			Success: s.Success,
+			Hops:    s.Hops.copy(),
+			Latency: s.Latency.copy(),
+		}
+	}
+	return cp(m.findNode), cp(m.findValue)
+}
+
+func newLookupMetrics() *lookupMetrics {
+	return &lookupMetrics{
+		mutex: new(sync.Mutex),
+		findNode: LookupStats{
+			Hops:    newHistogram(hopBounds),
+			Latency: newHistogram(latencyBounds),
+		},
+		findValue: LookupStats{
+			Hops:    newHistogram(hopBounds),
+			Latency: newHistogram(latencyBounds),
+		},
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+// the time a name stays registered without renewal
+const tName = time.Hour * 24
+
+// the prefix of the derived key of a name
+var namePrefix = []byte{0x2f, 0x6e, 0x61, 0x6d, 0x65, 0x2f}
+
+// names are lower case labels, such as pretty-dress-0716
+var nameFormat = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Errors for the name registry
+var (
+	ErrInvalidName   = errors.New("invalid name")
+	ErrNameTaken     = errors.New("name is owned by another key")
+	ErrNameNotFound  = errors.New("name not found")
+	ErrNameNotStored = errors.New("name not stored")
+	ErrNamePeerID    = errors.New("peer id is not derived from the key of the node")
+)
+
+// Register claims a name for the local node, mapping the name to the peer id
+// and addresses of the local node. A name belongs to the first key that
+// registers it, and stays registered as long as its owner renews it by calling
+// Register again before the registration expires
+func (kad *Kademlia) Register(name string) error {
+	if !nameFormat.MatchString(name) {
+		return ErrInvalidName
+	}
+	key, err := kad.privateKey()
+	if err != nil {
+		return err
+	}
+	pub, err := crypto.MarshalPublicKey(key.GetPublic())
+	if err != nil {
+		return err
+	}
+	if record, err := kad.Resolve(name); err == nil && !bytes.Equal(record.PublicKey, pub) {
+		return ErrNameTaken
+	}
+
+	self := kad.table.Self
+	record := &NameRecord{
+		Name:      name,
+		PeerId:    self.PeerId,
+		Addrs:     self.Addrs,
+		Expires:   time.Now().Add(tName).UnixNano(),
+		Seq:       uint64(time.Now().UnixNano()),
+		PublicKey: pub,
+	}
+	b, err := proto.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if record.Sig, err = key.Sign(b); err != nil {
+		return err
+	}
+	if !verifyNameRecord(record) {
+		return ErrNamePeerID
+	}
+	if b, err = proto.Marshal(record); err != nil {
+		return err
+	}
+
+	k, err := nameKey(name)
+	if err != nil {
+		return err
+	}
+	writes := kad.iterativeStore(&hashable{
+		key:  k,
+		data: b,
+		hash: k,
+		kind: RecordKind_NAME,
+	})
+	if writes == 0 {
+		return ErrNameNotStored
+	}
+	return nil
+}
+
+// Resolve looks up the record of a name, returns ErrNameNotFound if the name
+// is not registered or its registration has expired
+func (kad *Kademlia) Resolve(name string) (*NameRecord, error) {
+	if !nameFormat.MatchString(name) {
+		return nil, ErrInvalidName
+	}
+	key, err := nameKey(name)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := kad.iterativeFindValue(key)
+	if !ok {
+		return nil, ErrNameNotFound
+	}
+	record, ok := decodeNameRecord(p.Kind, p.Data)
+	if !ok || record.Name != name || !verifyNameRecord(record) {
+		return nil, ErrNameNotFound
+	}
+	return record, nil
+}
+
+// storeName verifies and stores a name record received in a STORE request. A
+// record replaces the stored one only if both are signed by the same key and
+// the new record is more recent, unless the stored record has expired
+func (kad *Kademlia) storeName(key []byte, record *NameRecord) bool {
+	if k, err := nameKey(record.Name); err != nil || !bytes.Equal(k, key) {
+		return false
+	}
+	if !nameFormat.MatchString(record.Name) || !verifyNameRecord(record) {
+		return false
+	}
+	if kind, b, _, ok := kad.store.GetRecord(key); ok {
+		if cur, ok := decodeNameRecord(kind, b); ok && verifyNameRecord(cur) {
+			if !bytes.Equal(cur.PublicKey, record.PublicKey) || cur.Seq >= record.Seq {
+				return false
+			}
+		}
+	}
+	ttl := time.Until(time.Unix(0, record.Expires))
+	if ttl > tExpire {
+		ttl = tExpire
+	}
+	b, err := proto.Marshal(record)
+	if err != nil {
+		return false
+	}
+	return kad.store.SetRecord(key, RecordKind_NAME, b, ttl) == nil
+}
+
+// nameOwned checks if a key holds a live name record, which ordinary values
+// must not overwrite
+func (kad *Kademlia) nameOwned(key []byte) bool {
+	kind, b, _, ok := kad.store.GetRecord(key)
+	if !ok {
+		return false
+	}
+	record, ok := decodeNameRecord(kind, b)
+	return ok && verifyNameRecord(record)
+}
+
+// verifyNameRecord checks that the record has not expired, that its peer id is
+// derived from its embedded public key, and its signature against the key
+func verifyNameRecord(record *NameRecord) bool {
+	if record.Expires <= time.Now().UnixNano() {
+		return false
+	}
+	pub, err := crypto.UnmarshalPublicKey(record.PublicKey)
+	if err != nil {
+		return false
+	}
+	pid, err := peer.IDB58Decode(string(record.PeerId))
+	if err != nil || !pid.MatchesPublicKey(pub) {
+		return false
+	}
+	t := *record
+	t.Sig = nil
+	b, err := proto.Marshal(&t)
+	if err != nil {
+		return false
+	}
+	ok, err := pub.Verify(b, record.Sig)
+	return err == nil && ok
+}
+
+// decodeNameRecord returns the record if the value is a record holding a name
+// record
+func decodeNameRecord(kind RecordKind, b []byte) (*NameRecord, bool) {
+	if kind != RecordKind_NAME {
+		return nil, false
+	}
+	record := new(NameRecord)
+	if err := proto.Unmarshal(b, record); err != nil {
+		return nil, false
+	}
+	return record, true
+}
+
+// nameKey derives the dht key of a name
+func nameKey(name string) ([]byte, error) {
+	return multihash.Sum(append(namePrefix, name...), h, -1)
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/dht"
+	"github.com/zigmahq/zigma/store"
+)
+
+func TestKademliaNames(t *testing.T) {
+	kads, nodes, _, done := mockKeyedNetwork(6)
+	defer done()
+
+	name := "pretty-dress-0716"
+	assert.Nil(t, kads[0].Register(name))
+
+	record, err := kads[3].Resolve(name)
+	assert.Nil(t, err)
+	assert.Equal(t, name, record.Name)
+	assert.Equal(t, nodes[0].PeerId, record.PeerId)
+
+	// first come ownership, other keys cannot take over the name
+	assert.Equal(t, dht.ErrNameTaken, kads[1].Register(name))
+
+	// the owner renews the registration
+	assert.Nil(t, kads[0].Register(name))
+	renewed, err := kads[4].Resolve(name)
+	assert.Nil(t, err)
+	assert.True(t, renewed.Seq > record.Seq)
+	assert.True(t, renewed.Expires >= record.Expires)
+
+	_, err = kads[2].Resolve("unknown-name")
+	assert.Equal(t, dht.ErrNameNotFound, err)
+
+	assert.Equal(t, dht.ErrInvalidName, kads[2].Register("Not A Name"))
+	_, err = kads[2].Resolve("-invalid")
+	assert.Equal(t, dht.ErrInvalidName, err)
+}
+
+func TestKademliaNamesPeerID(t *testing.T) {
+	// the peer ids of mock nodes are not derived from the keys of the nodes
+	kads, _, done := mockNetwork(3700, 3)
+	defer done()
+
+	assert.Equal(t, dht.ErrNamePeerID, kads[0].Register("borrowed-peer-id"))
+	_, err := kads[1].Resolve("borrowed-peer-id")
+	assert.Equal(t, dht.ErrNameNotFound, err)
+}
+
+func TestKademliaNoPrivateKey(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+	node := dht.MockNode(3800)
+	kad := dht.NewKademlia(node, db, dht.MockRPC(node, true))
+	defer kad.Stop()
+
+	assert.Equal(t, dht.ErrNoPrivateKey, kad.Register("unsigned"))
+	assert.Zero(t, kad.AddToSet(dht.String("unsigned set").Key(), []byte("entry"), time.Minute))
+	_, err := kad.FetchMail()
+	assert.Equal(t, dht.ErrNoPrivateKey, err)
+}
@@ -0,0 +1,219 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/multiformats/go-multihash"
+)
+
+const (
+	// the maximum number of bytes held by a single object chunk
+	objectChunkSize = 256 << 10
+
+	// the maximum number of links held by a single object node, this keeps an
+	// encoded object node well below the size of a chunk
+	objectFanout = 512
+)
+
+// Errors for object storage
+var (
+	ErrObjectNotFound  = errors.New("object not found")
+	ErrObjectCorrupted = errors.New("object corrupted")
+	ErrObjectNotStored = errors.New("object not stored")
+)
+
+// PutObject splits the content into chunks, stores every chunk as a content
+// addressed value, and publishes a merkle tree of object nodes linking the
+// chunks. Returns the key of the root object node
+func (kad *Kademlia) PutObject(r io.Reader) ([]byte, error) {
+	var (
+		links  []*ObjectLink
+		length uint64
+		buf    = make([]byte, objectChunkSize)
+	)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buf[:n])
+			key, writes := kad.Store(Bytes(chunk))
+			if writes == 0 {
+				return nil, ErrObjectNotStored
+			}
+			links = append(links, &ObjectLink{Hash: key, Length: uint64(n)})
+			length += uint64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var depth uint32
+	for len(links) > objectFanout {
+		var parents []*ObjectLink
+		for i := 0; i < len(links); i += objectFanout {
+			j := i + objectFanout
+			if j > len(links) {
+				j = len(links)
+			}
+			link, err := kad.putObjectNode(depth, links[i:j])
+			if err != nil {
+				return nil, err
+			}
+			parents = append(parents, link)
+		}
+		links = parents
+		depth++
+	}
+
+	root, err := kad.putObjectNode(depth, links)
+	if err != nil {
+		return nil, err
+	}
+	if root.Length != length {
+		return nil, ErrObjectCorrupted
+	}
+	return root.Hash, nil
+}
+
+// GetObject streams an object back from its root key. Chunks are fetched
+// in parallel, and verified against their hash before they are written to
+// the returned reader
+func (kad *Kademlia) GetObject(root []byte) (io.ReadCloser, error) {
+	node, err := kad.getObjectNode(root)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	pending := make(chan chan []byte, a)
+
+	go func() {
+		defer close(pending)
+		kad.walkObject(node, pending)
+	}()
+
+	go func() {
+		for ch := range pending {
+			chunk, ok := <-ch
+			if !ok {
+				pw.CloseWithError(ErrObjectCorrupted)
+				break
+			}
+			if _, err := pw.Write(chunk); err != nil {
+				break
+			}
+		}
+		pw.Close()
+		for range pending {
+		}
+	}()
+	return pr, nil
+}
+
+// walkObject visits the object tree depth first, and queues a fetch for every
+// chunk in order; a closed channel is queued in place of a missing node
+func (kad *Kademlia) walkObject(node *ObjectNode, pending chan<- chan []byte) bool {
+	for _, link := range node.Links {
+		if node.Depth > 0 {
+			child, err := kad.getObjectNode(link.Hash)
+			if err != nil {
+				ch := make(chan []byte)
+				close(ch)
+				pending <- ch
+				return false
+			}
+			if !kad.walkObject(child, pending) {
+				return false
+			}
+			continue
+		}
+		ch := make(chan []byte, 1)
+		pending <- ch
+		go func(link *ObjectLink) {
+			defer close(ch)
+			if p, ok := kad.findVerified(link.Hash); ok && uint64(len(p.Data)) == link.Length {
+				ch <- p.Data
+			}
+		}(link)
+	}
+	return true
+}
+
+// putObjectNode stores an object node linking to the provided links, and
+// returns a link to the stored node
+func (kad *Kademlia) putObjectNode(depth uint32, links []*ObjectLink) (*ObjectLink, error) {
+	node := &ObjectNode{Depth: depth, Links: links}
+	for _, link := range links {
+		node.Length += link.Length
+	}
+	b, err := proto.Marshal(node)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := multihash.Sum(b, h, -1)
+	if err != nil {
+		return nil, err
+	}
+	key, writes := kad.Store(&hashable{data: b, hash: hash, kind: RecordKind_OBJECT_NODE})
+	if writes == 0 {
+		return nil, ErrObjectNotStored
+	}
+	return &ObjectLink{Hash: key, Length: node.Length}, nil
+}
+
+// getObjectNode retrieves and verifies an object node
+func (kad *Kademlia) getObjectNode(key []byte) (*ObjectNode, error) {
+	p, ok := kad.findVerified(key)
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	if p.Kind != RecordKind_OBJECT_NODE {
+		return nil, ErrObjectCorrupted
+	}
+	node := new(ObjectNode)
+	if err := proto.Unmarshal(p.Data, node); err != nil {
+		return nil, ErrObjectCorrupted
+	}
+	return node, nil
+}
+
+// findVerified retrieves a content addressed value, and checks the value
+// against the multihash it is keyed by
+func (kad *Kademlia) findVerified(key []byte) (*Payload, bool) {
+	d, err := multihash.Decode(key)
+	if err != nil {
+		return nil, false
+	}
+	p, ok := kad.iterativeFindValue(key)
+	if !ok {
+		return nil, false
+	}
+	hash, err := multihash.Sum(p.Data, d.Code, d.Length)
+	if err != nil || !bytes.Equal(hash, key) {
+		return nil, false
+	}
+	return p, true
+}
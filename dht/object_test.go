@@ -0,0 +1,57 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/dht"
+)
+
+func TestKademliaPutGetObject(t *testing.T) {
+	kads, _, done := mockNetwork(1200, 12)
+	defer done()
+
+	b := make([]byte, 1<<20+123)
+	rand.New(rand.NewSource(1)).Read(b)
+
+	root, err := kads[0].PutObject(bytes.NewReader(b))
+	assert.Nil(t, err)
+	assert.NotEmpty(t, root)
+
+	r, err := kads[9].GetObject(root)
+	assert.Nil(t, err)
+	defer r.Close()
+
+	o, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, b, o)
+}
+
+func TestKademliaGetObjectNotFound(t *testing.T) {
+	kads, _, done := mockNetwork(1300, 4)
+	defer done()
+
+	hs := dht.String("missing object")
+	r, err := kads[0].GetObject(hs.Hash())
+	assert.Nil(t, r)
+	assert.Equal(t, dht.ErrObjectNotFound, err)
+}
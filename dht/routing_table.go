@@ -18,6 +18,7 @@
 package dht
 
 import (
+	"crypto/rand"
 	"sync"
 	"time"
 )
@@ -133,25 +134,87 @@ func (r *RoutingTable) MarkBucketRefreshed(idx int) {
 	r.refresh[idx] = time.Now()
 }
 
-// RandomNodeFromBucket returns a random node picked from specified bucket
-func (r *RoutingTable) RandomNodeFromBucket(idx int) *Node {
+// RandomIDFromBucket returns a random id in the range covered by the specified
+// bucket; ids in the i-th bucket share the first i bits with the local node, and
+// differ from the local node at the i-th bit
+func (r *RoutingTable) RandomIDFromBucket(idx int) []byte {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
 	if idx > len(r.Buckets)-1 {
 		return nil
 	}
-	bucket := r.Buckets[idx]
-	for node := range bucket.Iterator() {
-		return node
+	return randomIDInBucket(r.Self.Hash, idx)
+}
+
+// randomIDInBucket returns a random id sharing the first idx bits with the hash,
+// and differing from the hash at the idx-th bit
+func randomIDInBucket(hash []byte, idx int) []byte {
+	l := len(hash)
+	if idx < 0 || idx >= l*8 {
+		return nil
+	}
+	id := make([]byte, l)
+	if _, err := rand.Read(id); err != nil {
+		return nil
 	}
-	return nil
+	q, m := idx/8, uint(idx%8)
+	copy(id[:q], hash[:q])
+
+	mask := byte(0xff) << (8 - m)
+	flip := byte(0x80) >> m
+	id[q] = hash[q]&mask | ^hash[q]&flip | id[q]&^(mask|flip)
+	return id
 }
 
-func (r *RoutingTable) shouldBucketRefresh(idx int) bool {
+// Contains checks if a node is present in the routing table
+func (r *RoutingTable) Contains(node *Node) bool {
+	if !IsValidNode(node) {
+		return false
+	}
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
+	bucket := r.bucketFromNode(node)
+	return bucket.indexOf(node) > -1
+}
+
+// Snapshot returns the contacts of every bucket, and the time each bucket was
+// last refreshed
+func (r *RoutingTable) Snapshot() []BucketSnapshot {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	out := make([]BucketSnapshot, len(r.Buckets))
+	for i, bucket := range r.Buckets {
+		out[i] = BucketSnapshot{
+			Index:     i,
+			Refreshed: r.refresh[i],
+			Contacts:  make([]string, 0, bucket.Len()),
+		}
+		for node := range bucket.Iterator() {
+			out[i].Contacts = append(out[i].Contacts, string(node.HexString()))
+		}
+	}
+	return out
+}
+
+// depth returns the index of the non-empty bucket sharing the longest prefix
+// with the local node, returns -1 if the routing table is empty
+func (r *RoutingTable) depth() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for i := len(r.Buckets) - 1; i >= 0; i-- {
+		if r.Buckets[i].Len() > 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// shouldBucketRefresh expects the caller to hold the routing table lock
+func (r *RoutingTable) shouldBucketRefresh(idx int) bool {
 	if idx > len(r.refresh)-1 {
 		return false
 	}
@@ -103,6 +103,17 @@ func TestRoutingTableKclosest(t *testing.T) {
 	assert.Len(t, other, 20)
 }
 
+func TestRoutingTableRandomIDFromBucket(t *testing.T) {
+	table := dht.NewRoutingTable(dht.MockNode(-1))
+	for _, idx := range []int{0, 1, 7, 8, 9, 100, len(table.Buckets) - 1} {
+		id := table.RandomIDFromBucket(idx)
+		assert.Len(t, id, len(table.Self.Hash))
+		assert.Equal(t, idx, table.Self.ZeroPrefixLen(&dht.Node{Hash: id}))
+	}
+	assert.Nil(t, table.RandomIDFromBucket(-1))
+	assert.Nil(t, table.RandomIDFromBucket(len(table.Buckets)))
+}
+
 func TestRoutingTableBucketCap(t *testing.T) {
 	b1 := make([]byte, 4)
 	binary.LittleEndian.PutUint32(b1, uint32(0))
@@ -0,0 +1,284 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/multiformats/go-multihash"
+	"github.com/zigmahq/zigma/log"
+	"github.com/zigmahq/zigma/store"
+)
+
+// the number of values read from the store at a time by the scrubber
+const scrubBatch = 64
+
+// the number of values between two progress reports of the scrubber
+const scrubProgress = 10000
+
+// ScrubOptions encapsulates the settings of the integrity scrubber
+type ScrubOptions struct {
+	Rate       int           // the number of values verified per second, unlimited if not positive
+	Interval   time.Duration // the time between two passes over the store
+	Quarantine bool          // keep corrupt values in quarantine rather than dropping them
+	Logger     log.Logger    // receives the progress and the results of every pass
+}
+
+// scrubber holds the quit channel of the scrubber running in the background
+type scrubber struct {
+	mutex sync.Mutex
+	quit  chan struct{}
+}
+
+// replace closes the quit channel of the running scrubber, and keeps the quit
+// channel of the next one
+func (s *scrubber) replace(quit chan struct{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.quit != nil {
+		close(s.quit)
+	}
+	s.quit = quit
+}
+
+// ScrubResult reports the counters of a pass over the store
+type ScrubResult struct {
+	Scanned    int           // the number of values read
+	Verified   int           // the number of values matching the multihash they are keyed by
+	Skipped    int           // the number of records keyed by a derived key, such as erasure shards
+	Mismatched int           // the number of values not matching the multihash they are keyed by
+	Repaired   int           // the number of corrupt values replaced by a healthy copy
+	Elapsed    time.Duration // the time taken by the pass
+}
+
+// Scrub walks the values stored locally, and recomputes the multihash of the
+// values which are keyed by one. Erasure shards, shard manifests and names are
+// keyed by a derived multihash and never match, they are skipped. A value which
+// does not match its key is only known to be corrupt once a healthy copy is
+// found on the network. Corrupt values are dropped, or quarantined, and
+// replaced by the healthy copy
+func (kad *Kademlia) Scrub(opts ScrubOptions) ScrubResult {
+	return kad.scrubPass(opts, nil)
+}
+
+// StartScrubber runs a pass of Scrub at every interval in the background, until
+// the scrubber or the kademlia server is stopped. A running scrubber is
+// replaced by the new one
+func (kad *Kademlia) StartScrubber(opts ScrubOptions) {
+	if opts.Interval <= 0 {
+		opts.Interval = tReplicate
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.DefaultLogger
+	}
+	quit := make(chan struct{})
+	kad.scrub.replace(quit)
+	go func() {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				kad.scrubPass(opts, quit)
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+// StopScrubber stops the scrubber running in the background, if any
+func (kad *Kademlia) StopScrubber() {
+	kad.scrub.replace(nil)
+}
+
+// scrubPass runs a pass over the store, the pass is cut short when quit is closed
+func (kad *Kademlia) scrubPass(opts ScrubOptions, quit <-chan struct{}) ScrubResult {
+	var (
+		res   ScrubResult
+		start = time.Now()
+		next  []byte
+		tick  <-chan time.Time
+	)
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(opts.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	if opts.Logger != nil {
+		opts.Logger.Debug("DHT scrub started")
+	}
+
+	for {
+		// values are read in batches, so that the iterator is not held open
+		// while the values are verified at the configured rate
+		kad.paused.RLock()
+		keys, values := kad.scrubBatch(next)
+		kad.paused.RUnlock()
+		for i, key := range keys {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-quit:
+					return res
+				}
+			}
+			kad.paused.RLock()
+			kad.scrubValue(opts, key, values[i], &res)
+			kad.paused.RUnlock()
+			if res.Scanned%scrubProgress == 0 && opts.Logger != nil {
+				opts.Logger.Info("DHT scrub in progress",
+					log.Int("scanned", res.Scanned),
+					log.Int("mismatched", res.Mismatched),
+					log.Int("repaired", res.Repaired),
+				)
+			}
+		}
+		if len(keys) < scrubBatch {
+			break
+		}
+		next = append(keys[len(keys)-1], 0)
+	}
+
+	res.Elapsed = time.Since(start)
+	if opts.Logger != nil {
+		opts.Logger.Info("DHT scrub finished",
+			log.Int("scanned", res.Scanned),
+			log.Int("verified", res.Verified),
+			log.Int("skipped", res.Skipped),
+			log.Int("mismatched", res.Mismatched),
+			log.Int("repaired", res.Repaired),
+			log.String("elapsed", res.Elapsed.String()),
+		)
+	}
+	return res
+}
+
+// scrubBatch reads the values following the start key
+func (kad *Kademlia) scrubBatch(start []byte) (keys, values [][]byte) {
+	iter := kad.store.IterateWith(store.IteratorOptions{
+		Start: start,
+		Limit: scrubBatch,
+	})
+	defer iter.Done()
+
+	for iter.Next() {
+		item := iter.Item()
+		keys = append(keys, append([]byte{}, item.Key()...))
+		values = append(values, append([]byte{}, item.Value()...))
+	}
+	return
+}
+
+// scrubValue verifies a value against its key, and repairs the value if a
+// healthy copy is found on the network
+func (kad *Kademlia) scrubValue(opts ScrubOptions, key, val []byte, res *ScrubResult) {
+	res.Scanned++
+
+	d, err := multihash.Decode(key)
+	if err != nil {
+		return
+	}
+	hash, err := multihash.Sum(val, d.Code, d.Length)
+	if err != nil {
+		return
+	}
+	if bytes.Equal(hash, key) {
+		res.Verified++
+		return
+	}
+	if derivedRecord(kad.store.Kind(key), key, val) {
+		res.Skipped++
+		return
+	}
+	res.Mismatched++
+
+	kind, healthy, ttl, ok := kad.findHealthy(key, d)
+	if !ok {
+		if opts.Logger != nil {
+			opts.Logger.Debug("DHT scrub found no healthy copy",
+				log.String("key", hex.EncodeToString(key)),
+			)
+		}
+		return
+	}
+	if opts.Quarantine {
+		if err := kad.store.Quarantine(key); err != nil {
+			return
+		}
+	}
+	if err := kad.store.SetRecord(key, kind, healthy, ttl); err != nil {
+		return
+	}
+	res.Repaired++
+	if opts.Logger != nil {
+		opts.Logger.Warn("DHT scrub repaired a corrupt value",
+			log.String("key", hex.EncodeToString(key)),
+			log.Bool("quarantined", opts.Quarantine),
+		)
+	}
+}
+
+// findHealthy asks the closest nodes for a key, and returns the first value
+// which matches the multihash of the key along with the kind of record it
+// holds and its remaining lifetime
+func (kad *Kademlia) findHealthy(key []byte, d *multihash.DecodedMultihash) (RecordKind, []byte, time.Duration, bool) {
+	contacts := kad.iterativeFindNode(key)
+	for _, node := range contacts.Nodes() {
+		msg := compose(kad.table.Self).to(node).findValue(key)
+		rec := kad.write(msg)
+		out := <-rec(0)
+		if out == nil || out.GetPayload() == nil {
+			continue
+		}
+		payload := out.GetPayload()
+		ttl, ok := payloadTTL(payload)
+		if !ok {
+			continue
+		}
+		if hash, err := multihash.Sum(payload.Data, d.Code, d.Length); err == nil && bytes.Equal(hash, key) {
+			return payload.Kind, payload.Data, ttl, true
+		}
+	}
+	return RecordKind_RAW, nil, 0, false
+}
+
+// derivedRecord checks if a value is a record keyed by a derived key rather
+// than its content. Manifests and names are only taken for one if they are
+// signed and keyed by the key they derive, erasure shards cannot be checked on
+// their own and are rebuilt against the hash of the manifest
+func derivedRecord(kind RecordKind, key, val []byte) bool {
+	switch kind {
+	case RecordKind_SHARD:
+		return true
+	case RecordKind_SHARD_MANIFEST:
+		manifest, ok := decodeShardManifest(kind, val)
+		return ok && bytes.Equal(manifest.Key, key) && verifyManifest(manifest)
+	case RecordKind_NAME:
+		record, ok := decodeNameRecord(kind, val)
+		if !ok || !verifyNameRecord(record) {
+			return false
+		}
+		k, err := nameKey(record.Name)
+		return err == nil && bytes.Equal(k, key)
+	}
+	return false
+}
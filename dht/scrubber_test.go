@@ -0,0 +1,144 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/dht"
+	"github.com/zigmahq/zigma/store"
+)
+
+func TestKademliaScrub(t *testing.T) {
+	kads, _, stores, done := mockNetworkStores(3200, 4)
+	defer done()
+
+	content := dht.String("hello scrub")
+	healthy := dht.String("healthy value")
+	for _, s := range stores {
+		assert.Nil(t, store.Namespace(s, "d").Set(content.Key(), content.Data(), 0))
+	}
+
+	// a flipped bit in the local copy, a value keyed by a derived multihash,
+	// and a value which is not keyed by a multihash
+	derived, err := multihash.Sum([]byte("derived"), multihash.SHA3_512, -1)
+	assert.Nil(t, err)
+	corrupt := append([]byte{}, content.Data()...)
+	corrupt[0] ^= 0x01
+
+	data := store.Namespace(stores[0], "d")
+	assert.Nil(t, data.Set(content.Key(), corrupt, 0))
+	assert.Nil(t, data.Set(healthy.Key(), healthy.Data(), 0))
+	assert.Nil(t, data.Set(derived, []byte("shard"), 0))
+	assert.Nil(t, data.Set([]byte("plain"), []byte("value"), 0))
+
+	// an erasure shard is skipped, a value claiming to be a manifest is not
+	shard, err := multihash.Sum([]byte("shard key"), multihash.SHA3_512, -1)
+	assert.Nil(t, err)
+	poisoned := dht.String("poisoned manifest")
+	kstore := dht.NewKademliaStore(stores[0])
+	assert.Nil(t, kstore.SetRecord(shard, dht.RecordKind_SHARD, []byte("shard"), 0))
+	assert.Nil(t, kstore.SetRecord(poisoned.Key(), dht.RecordKind_SHARD_MANIFEST, []byte("poison"), 0))
+
+	res := kads[0].Scrub(dht.ScrubOptions{Rate: 1000, Quarantine: true})
+	assert.Equal(t, 6, res.Scanned)
+	assert.Equal(t, 1, res.Verified)
+	assert.Equal(t, 1, res.Skipped)
+	assert.Equal(t, 3, res.Mismatched)
+	assert.Equal(t, 1, res.Repaired)
+
+	b, ok := data.Get(content.Key())
+	assert.True(t, ok)
+	assert.Equal(t, content.Data(), b)
+
+	b, ok = store.Namespace(stores[0], "q").Get(content.Key())
+	assert.True(t, ok)
+	assert.Equal(t, corrupt, b)
+
+	// values without a healthy copy on the network are left in place
+	b, ok = data.Get(derived)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("shard"), b)
+
+	res = kads[0].Scrub(dht.ScrubOptions{})
+	assert.Equal(t, 2, res.Verified)
+	assert.Equal(t, 0, res.Repaired)
+}
+
+func TestKademliaScrubErasure(t *testing.T) {
+	kads, _, done := mockNetwork(3400, 8)
+	defer done()
+
+	hs := dht.String(strings.Repeat("erasure coded value ", 512))
+	_, placed := kads[0].StoreErasure(hs, 4, 6)
+	assert.Equal(t, 6, placed)
+
+	// shards and manifests are keyed by derived keys, and are never looked up
+	var skipped int
+	for _, kad := range kads {
+		res := kad.Scrub(dht.ScrubOptions{})
+		assert.Zero(t, res.Mismatched)
+		skipped += res.Skipped
+	}
+	assert.True(t, skipped >= placed+1)
+}
+
+func TestKademliaScrubKeepsExpiry(t *testing.T) {
+	kads, _, stores, done := mockNetworkStores(3300, 4)
+	defer done()
+
+	content := dht.String("hello expiry")
+	for _, s := range stores[1:] {
+		assert.Nil(t, store.Namespace(s, "d").Set(content.Key(), content.Data(), time.Minute*10))
+	}
+	data := store.Namespace(stores[0], "d")
+	assert.Nil(t, data.Set(content.Key(), []byte("corrupt"), 0))
+
+	res := kads[0].Scrub(dht.ScrubOptions{})
+	assert.Equal(t, 1, res.Repaired)
+
+	// the healthy copy keeps the expiration of the copy it was fetched from
+	b, expires, ok := data.GetWithTTL(content.Key())
+	assert.True(t, ok)
+	assert.Equal(t, content.Data(), b)
+	assert.True(t, time.Until(expires) > time.Minute*9)
+	assert.True(t, time.Until(expires) <= time.Minute*10)
+}
+
+func TestKademliaScrubTypedRecord(t *testing.T) {
+	kads, _, stores, done := mockNetworkStores(4000, 4)
+	defer done()
+
+	content := dht.String("object node")
+	for _, s := range stores {
+		assert.Nil(t, dht.NewKademliaStore(s).SetRecord(content.Key(), dht.RecordKind_OBJECT_NODE, content.Data(), 0))
+	}
+	assert.Nil(t, store.Namespace(stores[0], "d").Set(content.Key(), []byte("corrupt"), 0))
+
+	res := kads[0].Scrub(dht.ScrubOptions{Quarantine: true})
+	assert.Equal(t, 1, res.Repaired)
+
+	// the repaired value keeps the kind of record of the healthy copy
+	kind, b, _, ok := dht.NewKademliaStore(stores[0]).GetRecord(content.Key())
+	assert.True(t, ok)
+	assert.Equal(t, dht.RecordKind_OBJECT_NODE, kind)
+	assert.Equal(t, content.Data(), b)
+}
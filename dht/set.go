@@ -0,0 +1,263 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/libp2p/go-libp2p-core/crypto"
+)
+
+// the maximum number of entries returned in a page of a set
+const setPageSize = 64
+
+// AddToSet publishes an entry to a multi-value key. Every publisher holds a
+// single entry per set, signed with the key of the local node, and a later
+// entry replaces the earlier one of the same publisher. The entry expires
+// after the ttl, capped by the expiration of ordinary values; publishers are
+// expected to add their entries again before they expire. Returns the number
+// of nodes which accepted the entry
+func (kad *Kademlia) AddToSet(set, data []byte, ttl time.Duration) int {
+	key, err := kad.privateKey()
+	if err != nil {
+		return 0
+	}
+	return kad.addToSet(key, set, data, ttl)
+}
+
+// addToSet publishes an entry to a set, signed with the provided key
+func (kad *Kademlia) addToSet(key crypto.PrivKey, set, data []byte, ttl time.Duration) int {
+	if len(set) == 0 || len(data) == 0 {
+		return 0
+	}
+	if ttl <= 0 || ttl > tExpire {
+		ttl = tExpire
+	}
+	entry := &SetEntry{
+		Set:     set,
+		Data:    data,
+		Expires: time.Now().Add(ttl).UnixNano(),
+	}
+	if err := signSetEntry(key, entry); err != nil {
+		return 0
+	}
+	b, err := proto.Marshal(entry)
+	if err != nil {
+		return 0
+	}
+	return kad.iterativeStore(&hashable{
+		key:  set,
+		data: b,
+		hash: set,
+		kind: RecordKind_SET_ENTRY,
+	})
+}
+
+// FindSet retrieves the entries of a multi-value key. The closest nodes are
+// asked for every page of the set, and the entries they return are merged,
+// keeping the latest entry of every publisher. Entries with an invalid
+// signature, or which have expired, are left out
+func (kad *Kademlia) FindSet(set []byte) ([]*SetEntry, bool) {
+	contacts := kad.iterativeFindNode(set)
+	if contacts.Len() == 0 {
+		return nil, false
+	}
+
+	var (
+		mutex  sync.Mutex
+		wg     sync.WaitGroup
+		merged = make(map[string]*SetEntry)
+	)
+	for _, node := range contacts.Nodes() {
+		wg.Add(1)
+		go func(node *Node) {
+			defer wg.Done()
+			for _, entry := range kad.fetchSet(node, set) {
+				mutex.Lock()
+				if e, ok := merged[string(entry.PublicKey)]; !ok || e.Expires < entry.Expires {
+					merged[string(entry.PublicKey)] = entry
+				}
+				mutex.Unlock()
+			}
+		}(node)
+	}
+	wg.Wait()
+
+	if len(merged) == 0 {
+		return nil, false
+	}
+	entries := make([]*SetEntry, 0, len(merged))
+	for _, entry := range merged {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].PublicKey, entries[j].PublicKey) < 0
+	})
+	return entries, true
+}
+
+// fetchSet reads every page of a set from a node
+func (kad *Kademlia) fetchSet(node *Node, set []byte) []*SetEntry {
+	var entries []*SetEntry
+	for page := uint32(0); ; page++ {
+		msg := compose(kad.table.Self).to(node).findSetPage(set, page)
+		rec := kad.write(msg)
+		out := <-rec(0)
+		if out == nil || out.GetPayload() == nil {
+			return entries
+		}
+		p, ok := decodeSetPage(out.GetPayload().GetKind(), out.GetPayload().GetData())
+		if !ok || !bytes.Equal(p.Set, set) || p.Page != page {
+			return entries
+		}
+		now := time.Now().UnixNano()
+		for _, entry := range p.Entries {
+			if bytes.Equal(entry.Set, set) && entry.Expires > now && verifySetEntry(entry) {
+				entries = append(entries, entry)
+			}
+		}
+		if !p.More {
+			return entries
+		}
+	}
+}
+
+// addSetEntry verifies and stores an entry received in a STORE request
+func (kad *Kademlia) addSetEntry(set []byte, entry *SetEntry) bool {
+	ttl := time.Until(time.Unix(0, entry.Expires))
+	if !bytes.Equal(entry.Set, set) || ttl <= 0 || !verifySetEntry(entry) {
+		return false
+	}
+	if ttl > tExpire {
+		ttl = tExpire
+	}
+	b, err := proto.Marshal(entry)
+	if err != nil {
+		return false
+	}
+	// an entry only replaces the stored entry of the publisher if it expires
+	// later, so that a replayed older entry can't roll back the set; the
+	// stored entry itself is accepted again when it is republished
+	if v, ok := kad.store.SetEntry(set, entry.PublicKey); ok {
+		var stored SetEntry
+		if proto.Unmarshal(v, &stored) == nil {
+			if stored.Expires > entry.Expires || (stored.Expires == entry.Expires && !bytes.Equal(v, b)) {
+				return false
+			}
+		}
+	}
+	return kad.store.AddSetEntry(set, entry.PublicKey, b, ttl) == nil
+}
+
+// setPage returns a page of the entries stored locally for a set, encoded as a
+// SetPage record; returns false if no entry of the set is stored
+func (kad *Kademlia) setPage(set []byte, page uint32) ([]byte, bool) {
+	p := &SetPage{Set: set, Page: page}
+	skip := int(page) * setPageSize
+
+	var i int
+	iter := kad.store.SetEntries(set, skip+setPageSize+1)
+	for iter.Next() {
+		if i++; i <= skip {
+			continue
+		}
+		if len(p.Entries) == setPageSize {
+			p.More = true
+			break
+		}
+		entry := new(SetEntry)
+		if err := proto.Unmarshal(iter.Item().Value(), entry); err == nil {
+			p.Entries = append(p.Entries, entry)
+		}
+	}
+	iter.Done()
+
+	if i == 0 {
+		return nil, false
+	}
+	b, err := proto.Marshal(p)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// signSetEntry signs a set entry with the provided key
+func signSetEntry(key crypto.PrivKey, entry *SetEntry) error {
+	pub, err := crypto.MarshalPublicKey(key.GetPublic())
+	if err != nil {
+		return err
+	}
+	entry.PublicKey = pub
+	entry.Sig = nil
+	b, err := proto.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	sig, err := key.Sign(b)
+	if err != nil {
+		return err
+	}
+	entry.Sig = sig
+	return nil
+}
+
+// verifySetEntry checks the entry signature against its embedded public key
+func verifySetEntry(entry *SetEntry) bool {
+	pub, err := crypto.UnmarshalPublicKey(entry.PublicKey)
+	if err != nil {
+		return false
+	}
+	t := *entry
+	t.Sig = nil
+	b, err := proto.Marshal(&t)
+	if err != nil {
+		return false
+	}
+	ok, err := pub.Verify(b, entry.Sig)
+	return err == nil && ok
+}
+
+// decodeSetEntry returns the entry if the value is a record holding a set
+// entry
+func decodeSetEntry(kind RecordKind, b []byte) (*SetEntry, bool) {
+	if kind != RecordKind_SET_ENTRY {
+		return nil, false
+	}
+	entry := new(SetEntry)
+	if err := proto.Unmarshal(b, entry); err != nil {
+		return nil, false
+	}
+	return entry, true
+}
+
+// decodeSetPage returns the page if the value is a record holding a page of a
+// set
+func decodeSetPage(kind RecordKind, b []byte) (*SetPage, bool) {
+	if kind != RecordKind_SET_PAGE {
+		return nil, false
+	}
+	p := new(SetPage)
+	if err := proto.Unmarshal(b, p); err != nil {
+		return nil, false
+	}
+	return p, true
+}
@@ -0,0 +1,182 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht_test
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/dht"
+	"github.com/zigmahq/zigma/store"
+)
+
+func TestKademliaSet(t *testing.T) {
+	kads, _, done := mockNetwork(2600, 6)
+	defer done()
+
+	service := dht.String("service registry")
+	set := service.Key()
+
+	for i := 0; i < 3; i++ {
+		writes := kads[i].AddToSet(set, []byte(fmt.Sprintf("publisher %d", i)), time.Minute)
+		assert.True(t, writes > 0)
+	}
+	assert.True(t, kads[0].AddToSet(set, []byte("publisher 0 again"), time.Minute) > 0)
+
+	// an ordinary value stored under the key of a set
+	_, writes := kads[5].Store(service)
+	assert.True(t, writes > 0)
+
+	entries, ok := kads[4].FindSet(set)
+	assert.True(t, ok)
+	assert.Len(t, entries, 3)
+
+	data := make(map[string]bool)
+	for _, entry := range entries {
+		data[string(entry.Data)] = true
+	}
+	assert.True(t, data["publisher 0 again"])
+	assert.True(t, data["publisher 1"])
+	assert.True(t, data["publisher 2"])
+
+	b, ok := kads[4].FindValue(set)
+	assert.True(t, ok)
+	assert.Equal(t, service.Data(), b)
+
+	_, ok = kads[4].FindSet(dht.String("empty set").Key())
+	assert.False(t, ok)
+}
+
+func TestKademliaSetAndValueOnSameNode(t *testing.T) {
+	kads, _, done := mockNetwork(2650, 3)
+	defer done()
+
+	service := dht.String("shared key")
+	set := service.Key()
+
+	_, writes := kads[0].Store(service)
+	assert.True(t, writes > 0)
+	assert.True(t, kads[0].AddToSet(set, []byte("publisher"), time.Minute) > 0)
+
+	entries, ok := kads[2].FindSet(set)
+	assert.True(t, ok)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, []byte("publisher"), entries[0].Data)
+	}
+
+	b, ok := kads[2].FindValue(set)
+	assert.True(t, ok)
+	assert.Equal(t, service.Data(), b)
+
+	// a key holding only a set has no plain value
+	only := dht.String("set only").Key()
+	assert.True(t, kads[0].AddToSet(only, []byte("publisher"), time.Minute) > 0)
+
+	b, ok = kads[2].FindValue(only)
+	assert.False(t, ok)
+	assert.Nil(t, b)
+}
+
+func TestKademliaSetPagination(t *testing.T) {
+	kads, _, done := mockNetwork(2700, 4)
+	defer done()
+
+	set := dht.String("presence list").Key()
+	for i := 0; i < 150; i++ {
+		key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+		assert.Nil(t, err)
+		kads[0].SetPrivateKey(key)
+		assert.True(t, kads[0].AddToSet(set, []byte(fmt.Sprintf("peer %d", i)), time.Minute) > 0)
+	}
+
+	entries, ok := kads[3].FindSet(set)
+	assert.True(t, ok)
+	assert.Len(t, entries, 150)
+}
+
+func TestKademliaSetExpiry(t *testing.T) {
+	kads, _, done := mockNetwork(2800, 4)
+	defer done()
+
+	set := dht.String("short lived").Key()
+	kads[0].AddToSet(set, []byte("gone soon"), 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	_, ok := kads[1].FindSet(set)
+	assert.False(t, ok)
+}
+
+func TestKademliaSetReplay(t *testing.T) {
+	kads, _, done := mockNetwork(2850, 4)
+	defer done()
+
+	set := dht.String("replayed set").Key()
+	assert.True(t, kads[0].AddToSet(set, []byte("newer"), 2*time.Minute) > 0)
+	// an entry of the same publisher which expires earlier is rejected
+	assert.Zero(t, kads[0].AddToSet(set, []byte("older"), time.Minute))
+
+	entries, ok := kads[3].FindSet(set)
+	assert.True(t, ok)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, []byte("newer"), entries[0].Data)
+	}
+}
+
+func TestKademliaReplicateSetEntry(t *testing.T) {
+	kads, _, stores, done := mockNetworkStores(3500, 4)
+	defer done()
+
+	set := dht.String("replicated set").Key()
+	assert.True(t, kads[0].AddToSet(set, []byte("entry"), time.Minute) > 0)
+
+	// a network which has never seen the set
+	others, _, othersDone := mockNetwork(3550, 4)
+	defer othersDone()
+
+	var pending int
+	for _, s := range stores {
+		// mark the set entries as due for replication
+		rs := store.Namespace(s, "rs")
+		var keys [][]byte
+		iter := rs.Iterate(nil)
+		for iter.Next() {
+			keys = append(keys, append([]byte{}, iter.Item().Key()...))
+		}
+		iter.Done()
+		for _, key := range keys {
+			assert.Nil(t, rs.Set(key, []byte{}, time.Minute))
+		}
+
+		for item := range dht.NewKademliaStore(s).PendingReplication() {
+			assert.Equal(t, set, item.Hash())
+			_, writes := others[0].Store(item)
+			assert.True(t, writes > 0)
+			pending++
+		}
+	}
+	assert.True(t, pending > 0)
+
+	entries, ok := others[3].FindSet(set)
+	assert.True(t, ok)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, []byte("entry"), entries[0].Data)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/multiformats/go-multihash"
+)
+
+// the number of hex digits of the digest used to label a contact
+const labelDigits = 12
+
+// Stats encapsulates the counters of a running dht node
+type Stats struct {
+	Contacts           int         `json:"contacts"`
+	Keys               int         `json:"keys"`
+	Bytes              int64       `json:"bytes"`
+	PendingReplication int         `json:"pending_replication"`
+	FindNode           LookupStats `json:"find_node"`
+	FindValue          LookupStats `json:"find_value"`
+}
+
+// BucketSnapshot encapsulates the contacts of a bucket, and the time the bucket
+// was last refreshed
+type BucketSnapshot struct {
+	Index     int       `json:"index"`
+	Refreshed time.Time `json:"refreshed"`
+	Contacts  []string  `json:"contacts"`
+}
+
+// Snapshot encapsulates the routing table and the counters of a dht node at a
+// point in time
+type Snapshot struct {
+	Self    string           `json:"self"`
+	Time    time.Time        `json:"time"`
+	Client  bool             `json:"client"`
+	Stats   Stats            `json:"stats"`
+	Buckets []BucketSnapshot `json:"buckets"`
+}
+
+// Stats returns the counters of the local node
+func (kad *Kademlia) Stats() Stats {
+	keys, bytes, pending := kad.store.Stats()
+	fn, fv := kad.metrics.copy()
+	return Stats{
+		Contacts:           kad.table.Size(),
+		Keys:               keys,
+		Bytes:              bytes,
+		PendingReplication: pending,
+		FindNode:           fn,
+		FindValue:          fv,
+	}
+}
+
+// Snapshot returns the routing table along with the counters of the local node
+func (kad *Kademlia) Snapshot() *Snapshot {
+	return &Snapshot{
+		Self:    string(kad.table.Self.HexString()),
+		Time:    time.Now().UTC(),
+		Client:  kad.client,
+		Stats:   kad.Stats(),
+		Buckets: kad.table.Snapshot(),
+	}
+}
+
+// WriteJSON writes the snapshot in json format
+func (s *Snapshot) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// WriteGraphviz writes the routing table in graphviz dot format, contacts are
+// grouped by the bucket they belong to. Graph nodes are named by the full id of
+// the contacts, and labelled by the leading digits of their digest
+func (s *Snapshot) WriteGraphviz(w io.Writer) error {
+	var sb strings.Builder
+	self := s.Self
+
+	sb.WriteString("digraph dht {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	fmt.Fprintf(&sb, "  %q [shape=doublecircle, label=%q];\n", self, shortLabel(self))
+	for _, bucket := range s.Buckets {
+		if len(bucket.Contacts) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "  subgraph cluster_%d {\n", bucket.Index)
+		fmt.Fprintf(&sb, "    label=%q;\n", fmt.Sprintf("bucket %d", bucket.Index))
+		for _, contact := range bucket.Contacts {
+			fmt.Fprintf(&sb, "    %q [label=%q];\n", contact, shortLabel(contact))
+		}
+		sb.WriteString("  }\n")
+		for _, contact := range bucket.Contacts {
+			fmt.Fprintf(&sb, "  %q -> %q;\n", self, contact)
+		}
+	}
+	sb.WriteString("}\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// shortLabel returns the leading digits of the digest of a hex encoded id, the
+// multihash prefix shared by every id is left out
+func shortLabel(id string) string {
+	if b, err := hex.DecodeString(id); err == nil {
+		if d, err := multihash.Decode(b); err == nil {
+			id = hex.EncodeToString(d.Digest)
+		}
+	}
+	if len(id) > labelDigits {
+		return id[:labelDigits]
+	}
+	return id
+}
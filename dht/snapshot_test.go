@@ -0,0 +1,94 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/dht"
+)
+
+func TestKademliaStats(t *testing.T) {
+	kads, _, done := mockNetwork(1900, 8)
+	defer done()
+
+	hs := dht.String("hello stats")
+	_, writes := kads[0].Store(hs)
+	assert.True(t, writes > 0)
+
+	_, ok := kads[1].FindValue(hs.Key())
+	assert.True(t, ok)
+
+	stats := kads[1].Stats()
+	assert.Equal(t, 7, stats.Contacts)
+	assert.Equal(t, uint64(1), stats.FindValue.Total)
+	assert.Equal(t, uint64(1), stats.FindValue.Success)
+	assert.Equal(t, uint64(1), stats.FindValue.Hops.Count)
+	assert.True(t, stats.FindNode.Total > 0)
+
+	// the value is held by a contact found in the FIND_NODE round, and every
+	// FIND_NODE lookup is a single round
+	assert.Equal(t, float64(2), stats.FindValue.Hops.Sum)
+	assert.Equal(t, float64(stats.FindNode.Total), stats.FindNode.Hops.Sum)
+
+	var keys int
+	for _, kad := range kads {
+		s := kad.Stats()
+		keys += s.Keys
+		assert.Equal(t, int64(s.Keys*len(hs.Data())), s.Bytes)
+	}
+	assert.True(t, keys >= writes)
+}
+
+func TestKademliaSnapshot(t *testing.T) {
+	kads, _, done := mockNetwork(2000, 5)
+	defer done()
+
+	snapshot := kads[0].Snapshot()
+	assert.Len(t, snapshot.Buckets, len(kads[0].Table().Buckets))
+
+	var contacts int
+	for _, bucket := range snapshot.Buckets {
+		contacts += len(bucket.Contacts)
+		assert.False(t, bucket.Refreshed.IsZero())
+	}
+	assert.Equal(t, 4, contacts)
+
+	var buf bytes.Buffer
+	assert.Nil(t, snapshot.WriteJSON(&buf))
+
+	var o dht.Snapshot
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &o))
+	assert.Equal(t, snapshot.Self, o.Self)
+	assert.Equal(t, snapshot.Stats.Contacts, o.Stats.Contacts)
+
+	buf.Reset()
+	assert.Nil(t, snapshot.WriteGraphviz(&buf))
+	assert.True(t, strings.HasPrefix(buf.String(), "digraph dht {"))
+	assert.Equal(t, 4, strings.Count(buf.String(), "->"))
+	for _, bucket := range snapshot.Buckets {
+		for _, contact := range bucket.Contacts {
+			assert.Contains(t, buf.String(), fmt.Sprintf("%q -> %q;", snapshot.Self, contact))
+		}
+	}
+	assert.NotContains(t, buf.String(), `label="1440`)
+}
@@ -17,56 +17,305 @@
 package dht
 
 import (
+	"encoding/binary"
+	"io"
 	"time"
 
-	"github.com/multiformats/go-multihash"
 	"github.com/zigmahq/zigma/store"
 )
 
-var (
-	prefixStoreReplication = []byte{0x72, 0x21}
-	prefixStoreData        = []byte{0x64, 0x21}
-)
+// the name of the schema of the kademlia store keys
+const storeSchema = "dht"
+
+// storeMigrations upgrade the key layout of the kademlia store, a migration is
+// appended whenever a prefix or the encoding of a value changes
+var storeMigrations = []store.Migration{
+	{
+		Version:     1,
+		Description: "values, record kinds, replication timestamps and set entries in namespaces",
+	},
+}
 
-// KademliaStore extends store.Store key-value storage
+// MigrateStore upgrades the keys of a store to the layout of the kademlia
+// store, returns store.ErrSchemaTooNew if the store was written by a newer
+// version
+func MigrateStore(s store.Store) error {
+	return store.Migrate(s, storeSchema, storeMigrations)
+}
+
+// KademliaStore extends store.Store key-value storage, values, the kinds of
+// typed records, replication timestamps, set entries, the replication
+// timestamps of set entries and quarantined values are kept in their own
+// namespaces. The methods of store.Store operate on the values, backups are
+// taken of the underlying store
 type KademliaStore struct {
 	store.Store
-	replicating bool
+	data           *store.NamespaceStore
+	kinds          *store.NamespaceStore
+	replication    *store.NamespaceStore
+	sets           *store.NamespaceStore
+	setReplication *store.NamespaceStore
+	quarantine     *store.NamespaceStore
+	replicating    bool
+}
+
+// setKey composes <varint set length><set>, the entries of a set are keyed by
+// the set key followed by the publisher key
+func (s *KademliaStore) setKey(set []byte) []byte {
+	buf := make([]byte, binary.MaxVarintLen64+len(set))
+	n := binary.PutUvarint(buf, uint64(len(set)))
+	n += copy(buf[n:], set)
+	return buf[:n]
+}
+
+// splitSetKey returns the set and the publisher of a set entry key
+func splitSetKey(key []byte) ([]byte, []byte, bool) {
+	l, n := binary.Uvarint(key)
+	if n <= 0 || uint64(len(key)-n) < l {
+		return nil, nil, false
+	}
+	return key[n : n+int(l)], key[n+int(l):], true
 }
 
-func (s *KademliaStore) dataKey(key []byte) []byte {
-	return append(prefixStoreData, key...)
+// AddSetEntry inserts the entry of a publisher to a set, replacing the earlier
+// entry of the same publisher. The entry and its replication key are written
+// in a single transaction
+func (s *KademliaStore) AddSetEntry(set, publisher, entry []byte, ttl time.Duration) error {
+	b, err := time.Now().UTC().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	key := append(s.setKey(set), publisher...)
+	return s.Store.Update(func(txn store.Txn) error {
+		if err := s.sets.Txn(txn).Set(key, entry, ttl); err != nil {
+			return err
+		}
+		return s.setReplication.Txn(txn).Set(key, b, ttl)
+	})
 }
 
-func (s *KademliaStore) replicationKey(key []byte) []byte {
-	return append(prefixStoreReplication, key...)
+// SetEntry retrieves the entry of a publisher in a set
+func (s *KademliaStore) SetEntry(set, publisher []byte) ([]byte, bool) {
+	return s.sets.Get(append(s.setKey(set), publisher...))
+}
+
+// DeleteSetEntry removes the entry of a publisher from a set
+func (s *KademliaStore) DeleteSetEntry(set, publisher []byte) error {
+	key := append(s.setKey(set), publisher...)
+	return s.Store.Update(func(txn store.Txn) error {
+		if err := s.sets.Txn(txn).Delete(key); err != nil {
+			return err
+		}
+		return s.setReplication.Txn(txn).Delete(key)
+	})
+}
+
+// SetEntries iterates the entries of a set, at most limit entries are visited
+// if the limit is positive
+func (s *KademliaStore) SetEntries(set []byte, limit int) store.Iterator {
+	return s.sets.IterateWith(store.IteratorOptions{
+		Prefix: s.setKey(set),
+		Limit:  limit,
+	})
 }
 
 // Get retrieves a key-value pair from storage
 func (s *KademliaStore) Get(key []byte) (data []byte, found bool) {
-	return s.Store.Get(s.dataKey(key))
+	return s.data.Get(key)
+}
+
+// GetWithTTL retrieves a key-value pair from storage along with its expiration
+// time, the zero time if the value never expires
+func (s *KademliaStore) GetWithTTL(key []byte) ([]byte, time.Time, bool) {
+	return s.data.GetWithTTL(key)
+}
+
+// Set insert key value pair to storage as a raw value
+func (s *KademliaStore) Set(key, val []byte, ttl time.Duration) error {
+	return s.SetRecord(key, RecordKind_RAW, val, ttl)
+}
+
+// SetRecord inserts a value along with the kind of record it holds, the value,
+// its kind and its replication key are written in a single transaction
+func (s *KademliaStore) SetRecord(key []byte, kind RecordKind, val []byte, ttl time.Duration) error {
+	b, err := time.Now().UTC().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.Store.Update(func(txn store.Txn) error {
+		if err := s.data.Txn(txn).Set(key, val, ttl); err != nil {
+			return err
+		}
+		kinds := s.kinds.Txn(txn)
+		if kind == RecordKind_RAW {
+			err = kinds.Delete(key)
+		} else {
+			err = kinds.Set(key, []byte{byte(kind)}, ttl)
+		}
+		if err != nil {
+			return err
+		}
+		return s.replication.Txn(txn).Set(key, b, ttl)
+	})
+}
+
+// GetVersioned retrieves a key-value pair from storage along with its version
+func (s *KademliaStore) GetVersioned(key []byte) ([]byte, uint64, bool) {
+	return s.data.GetVersioned(key)
 }
 
-// Set insert key value pair to storage
-func (s *KademliaStore) Set(key, val []byte, ttl time.Duration) {
-	s.Store.Set(s.dataKey(key), val, ttl)
-	if b, err := time.Now().UTC().MarshalBinary(); err == nil {
-		s.Store.Set(s.replicationKey(key), b, ttl)
+// CompareAndSet inserts a raw value if the version of the key is still the
+// version read by GetVersioned, the value is then recorded for replication
+func (s *KademliaStore) CompareAndSet(key []byte, version uint64, val []byte, ttl time.Duration) error {
+	if err := s.data.CompareAndSet(key, version, val, ttl); err != nil {
+		return err
 	}
+	b, err := time.Now().UTC().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.Store.Update(func(txn store.Txn) error {
+		if err := s.kinds.Txn(txn).Delete(key); err != nil {
+			return err
+		}
+		return s.replication.Txn(txn).Set(key, b, ttl)
+	})
+}
+
+// Update runs fn in a read-write transaction on the values, values are written
+// as raw values and recorded for replication as Set does
+func (s *KademliaStore) Update(fn func(store.Txn) error) error {
+	return s.Store.Update(func(txn store.Txn) error {
+		return fn(&kademliaTxn{s, txn})
+	})
+}
+
+// Backup is not supported by the kademlia store, the underlying store is backed
+// up instead
+func (s *KademliaStore) Backup(w io.Writer, since uint64) (uint64, error) {
+	return s.data.Backup(w, since)
+}
+
+// Restore is not supported by the kademlia store, backups are restored into the
+// underlying store instead
+func (s *KademliaStore) Restore(r io.Reader) error {
+	return s.data.Restore(r)
+}
+
+// GetRecord retrieves a value along with the kind of record it holds and its
+// expiration
+func (s *KademliaStore) GetRecord(key []byte) (RecordKind, []byte, time.Time, bool) {
+	data, expires, ok := s.data.GetWithTTL(key)
+	if !ok {
+		return RecordKind_RAW, nil, time.Time{}, false
+	}
+	return s.Kind(key), data, expires, true
+}
+
+// Kind returns the kind of record stored under a key, RecordKind_RAW for raw
+// values
+func (s *KademliaStore) Kind(key []byte) RecordKind {
+	b, ok := s.kinds.Get(key)
+	if !ok || len(b) != 1 {
+		return RecordKind_RAW
+	}
+	return RecordKind(b[0])
 }
 
 // Delete removes a key-value pair from storage
-func (s *KademliaStore) Delete(key []byte) {
-	s.Store.Delete(s.dataKey(key))
-	s.Store.Delete(s.replicationKey(key))
+func (s *KademliaStore) Delete(key []byte) error {
+	return s.Store.Update(func(txn store.Txn) error {
+		if err := s.data.Txn(txn).Delete(key); err != nil {
+			return err
+		}
+		if err := s.kinds.Txn(txn).Delete(key); err != nil {
+			return err
+		}
+		return s.replication.Txn(txn).Delete(key)
+	})
+}
+
+// Quarantine moves a value out of the data namespace, the value is kept for
+// inspection until the expiration of ordinary values
+func (s *KademliaStore) Quarantine(key []byte) error {
+	return s.Store.Update(func(txn store.Txn) error {
+		data := s.data.Txn(txn)
+		val, ok := data.Get(key)
+		if !ok {
+			return nil
+		}
+		if err := s.quarantine.Txn(txn).Set(key, val, tExpire); err != nil {
+			return err
+		}
+		if err := data.Delete(key); err != nil {
+			return err
+		}
+		if err := s.kinds.Txn(txn).Delete(key); err != nil {
+			return err
+		}
+		return s.replication.Txn(txn).Delete(key)
+	})
+}
+
+// Quarantined iterates the values moved out of the data namespace
+func (s *KademliaStore) Quarantined() store.Iterator {
+	return s.quarantine.Iterate(nil)
 }
 
-// Iterate iterates key-value pairs existed in storage
+// Iterate iterates key-value pairs existed in storage, the TTL of an item is
+// the expiration time of the value
 func (s *KademliaStore) Iterate(key []byte) store.Iterator {
-	return s.Store.Iterate(s.dataKey(key))
+	return s.data.Iterate(key)
+}
+
+// IterateWith iterates key-value pairs existed in storage, the TTL of an item
+// is the expiration time of the value
+func (s *KademliaStore) IterateWith(opts store.IteratorOptions) store.Iterator {
+	return s.data.IterateWith(opts)
+}
+
+// Watch delivers the events of the values with the prefix
+func (s *KademliaStore) Watch(prefix []byte) (<-chan store.Event, func()) {
+	return s.data.Watch(prefix)
+}
+
+// kademliaTxn keeps the kinds and the replication keys of the values written in
+// a transaction
+type kademliaTxn struct {
+	s   *KademliaStore
+	txn store.Txn
+}
+
+func (t *kademliaTxn) Set(key, val []byte, ttl time.Duration) error {
+	b, err := time.Now().UTC().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := t.s.data.Txn(t.txn).Set(key, val, ttl); err != nil {
+		return err
+	}
+	if err := t.s.kinds.Txn(t.txn).Delete(key); err != nil {
+		return err
+	}
+	return t.s.replication.Txn(t.txn).Set(key, b, ttl)
+}
+
+func (t *kademliaTxn) Get(key []byte) ([]byte, bool) {
+	return t.s.data.Txn(t.txn).Get(key)
+}
+
+func (t *kademliaTxn) Delete(key []byte) error {
+	if err := t.s.data.Txn(t.txn).Delete(key); err != nil {
+		return err
+	}
+	if err := t.s.kinds.Txn(t.txn).Delete(key); err != nil {
+		return err
+	}
+	return t.s.replication.Txn(t.txn).Delete(key)
 }
 
-// PendingReplication returns pending replication items
+// PendingReplication returns pending replication items, the items are routed
+// by the key the values are stored under and keep their kind and expiration
 func (s *KademliaStore) PendingReplication() <-chan Hashable {
 	var ch = make(chan Hashable)
 	go func() {
@@ -77,40 +326,96 @@ func (s *KademliaStore) PendingReplication() <-chan Hashable {
 		s.replicating = true
 		defer func() { s.replicating = false }()
 
-		iter := s.Store.Iterate(prefixStoreReplication)
+		iter := s.replication.Iterate(nil)
 		defer iter.Done()
 
 		for iter.Next() {
-			var last time.Time
 			item := iter.Item()
-			last.UnmarshalBinary(item.Value())
-			if !last.IsZero() && tReplicate > time.Since(last) {
+			if !s.needsReplication(item) {
 				continue
 			}
 
-			dkey := item.Key()[len(prefixStoreReplication):]
-			data, ok := s.Get(dkey)
+			dkey := append([]byte{}, item.Key()...)
+			kind, data, expires, ok := s.GetRecord(dkey)
 			if !ok {
 				continue
 			}
-			hash, err := multihash.Sum(data, h, -1)
-			if err != nil {
+			ch <- &hashable{
+				key:     dkey,
+				data:    data,
+				hash:    dkey,
+				kind:    kind,
+				expires: expires,
+			}
+		}
+
+		sets := s.setReplication.Iterate(nil)
+		defer sets.Done()
+
+		for sets.Next() {
+			item := sets.Item()
+			if !s.needsReplication(item) {
+				continue
+			}
+
+			set, _, ok := splitSetKey(item.Key())
+			if !ok {
+				continue
+			}
+			entry, expires, ok := s.sets.GetWithTTL(item.Key())
+			if !ok {
 				continue
 			}
 			ch <- &hashable{
-				key:  dkey,
-				data: data,
-				hash: hash,
+				key:     append([]byte{}, set...),
+				data:    entry,
+				hash:    append([]byte{}, set...),
+				kind:    RecordKind_SET_ENTRY,
+				expires: expires,
 			}
 		}
 	}()
 	return ch
 }
 
+// Stats returns the number of keys, the number of bytes held by the values, and
+// the number of keys and set entries pending replication
+func (s *KademliaStore) Stats() (keys int, bytes int64, pending int) {
+	iter := s.data.Iterate(nil)
+	for iter.Next() {
+		keys++
+		bytes += int64(len(iter.Item().Value()))
+	}
+	iter.Done()
+
+	for _, ns := range []*store.NamespaceStore{s.replication, s.setReplication} {
+		iter = ns.Iterate(nil)
+		for iter.Next() {
+			if s.needsReplication(iter.Item()) {
+				pending++
+			}
+		}
+		iter.Done()
+	}
+	return
+}
+
+func (s *KademliaStore) needsReplication(item store.Item) bool {
+	var last time.Time
+	last.UnmarshalBinary(item.Value())
+	return last.IsZero() || tReplicate <= time.Since(last)
+}
+
 // NewKademliaStore initializes kademlia store
-func NewKademliaStore(store store.Store) *KademliaStore {
+func NewKademliaStore(s store.Store) *KademliaStore {
 	return &KademliaStore{
-		Store:       store,
-		replicating: false,
+		Store:          s,
+		data:           store.Namespace(s, "d"),
+		kinds:          store.Namespace(s, "k"),
+		replication:    store.Namespace(s, "r"),
+		sets:           store.Namespace(s, "s"),
+		setReplication: store.Namespace(s, "rs"),
+		quarantine:     store.Namespace(s, "q"),
+		replicating:    false,
 	}
 }
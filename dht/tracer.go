@@ -0,0 +1,181 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zigmahq/zigma/log"
+)
+
+// TraceEventType defines the type of a lookup trace event
+type TraceEventType string
+
+// Defines the types of lookup trace events
+const (
+	TraceLookupStarted  TraceEventType = "lookup_started"
+	TraceRPCSent        TraceEventType = "rpc_sent"
+	TraceRPCReply       TraceEventType = "rpc_reply"
+	TraceRPCTimeout     TraceEventType = "rpc_timeout"
+	TraceContactLearned TraceEventType = "contact_learned"
+	TraceLookupFinished TraceEventType = "lookup_finished"
+)
+
+// Defines the reasons a lookup terminates
+const (
+	TraceReasonValueFound = "value_found"
+	TraceReasonExhausted  = "contacts_exhausted"
+	TraceReasonNoContacts = "no_contacts"
+)
+
+// TraceEvent encapsulates a single step of a lookup, every event of the same
+// lookup shares the lookup id
+type TraceEvent struct {
+	Lookup string         `json:"lookup"`
+	Type   TraceEventType `json:"type"`
+	Time   time.Time      `json:"time"`
+	RPC    string         `json:"rpc"`
+	Key    string         `json:"key"`
+	Node   string         `json:"node,omitempty"`
+	Reason string         `json:"reason,omitempty"`
+}
+
+// Tracer receives the events emitted by dht lookups
+type Tracer interface {
+	Trace(TraceEvent)
+}
+
+// SetTracer attaches a tracer to the kademlia lookups, passing nil detaches
+// the current tracer
+func (kad *Kademlia) SetTracer(tracer Tracer) {
+	kad.tracer.Store(tracerHolder{tracer})
+}
+
+type tracerHolder struct {
+	tracer Tracer
+}
+
+// lookupTrace tags the events of a lookup with the lookup id, a nil
+// lookupTrace discards every event
+type lookupTrace struct {
+	tracer Tracer
+	id     string
+	key    string
+}
+
+func (kad *Kademlia) newLookupTrace(key []byte) *lookupTrace {
+	th, _ := kad.tracer.Load().(tracerHolder)
+	if th.tracer == nil {
+		return nil
+	}
+	return &lookupTrace{
+		tracer: th.tracer,
+		id:     uuid.New().String(),
+		key:    hex.EncodeToString(key),
+	}
+}
+
+func (t *lookupTrace) emit(typ TraceEventType, rpc MessageType, node *Node, reason string) {
+	if t == nil {
+		return
+	}
+	ev := TraceEvent{
+		Lookup: t.id,
+		Type:   typ,
+		Time:   time.Now().UTC(),
+		RPC:    rpc.String(),
+		Key:    t.key,
+		Reason: reason,
+	}
+	if node != nil {
+		ev.Node = string(node.HexString())
+	}
+	t.tracer.Trace(ev)
+}
+
+type logTracer struct {
+	logger log.Logger
+}
+
+// NewLogTracer returns a tracer that writes lookup events to a logger at trace
+// level
+func NewLogTracer(logger log.Logger) Tracer {
+	return &logTracer{logger}
+}
+
+func (l *logTracer) Trace(ev TraceEvent) {
+	fs := []log.Field{
+		log.String("lookup", ev.Lookup),
+		log.String("rpc", ev.RPC),
+		log.String("key", ev.Key),
+	}
+	if len(ev.Node) > 0 {
+		fs = append(fs, log.String("node", ev.Node))
+	}
+	if len(ev.Reason) > 0 {
+		fs = append(fs, log.String("reason", ev.Reason))
+	}
+	l.logger.Trace(string(ev.Type), fs...)
+}
+
+// JSONTracer writes lookup events as json lines, for offline analysis
+type JSONTracer struct {
+	mutex  *sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+// Trace writes an event to the underlying writer
+func (j *JSONTracer) Trace(ev TraceEvent) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	_ = j.enc.Encode(ev)
+}
+
+// Close closes the underlying trace file, if the tracer owns one
+func (j *JSONTracer) Close() error {
+	if j.closer == nil {
+		return nil
+	}
+	return j.closer.Close()
+}
+
+// NewJSONTracer returns a tracer that writes lookup events to a writer
+func NewJSONTracer(w io.Writer) *JSONTracer {
+	return &JSONTracer{
+		mutex: new(sync.Mutex),
+		enc:   json.NewEncoder(w),
+	}
+}
+
+// OpenTraceFile returns a tracer that appends lookup events to a file
+func OpenTraceFile(path string) (*JSONTracer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	t := NewJSONTracer(f)
+	t.closer = f
+	return t, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 zigma authors
+// This file is part of the zigma library.
+//
+// The zigma library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zigma library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+
+package dht_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/dht"
+)
+
+func TestKademliaTracer(t *testing.T) {
+	kads, _, done := mockNetwork(2100, 6)
+	defer done()
+
+	hs := dht.String("hello tracer")
+	_, writes := kads[0].Store(hs)
+	assert.True(t, writes > 0)
+
+	var buf bytes.Buffer
+	kads[1].SetTracer(dht.NewJSONTracer(&buf))
+	_, ok := kads[1].FindValue(hs.Key())
+	assert.True(t, ok)
+	kads[1].SetTracer(nil)
+
+	var events []dht.TraceEvent
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var ev dht.TraceEvent
+		assert.Nil(t, dec.Decode(&ev))
+		events = append(events, ev)
+	}
+	assert.True(t, len(events) > 2)
+
+	first, last := events[0], events[len(events)-1]
+	assert.Equal(t, dht.TraceLookupStarted, first.Type)
+	assert.Equal(t, dht.TraceLookupFinished, last.Type)
+	assert.Equal(t, dht.TraceReasonValueFound, last.Reason)
+
+	var sent, replies int
+	for _, ev := range events {
+		assert.Equal(t, first.Lookup, ev.Lookup)
+		switch ev.Type {
+		case dht.TraceRPCSent:
+			sent++
+		case dht.TraceRPCReply:
+			replies++
+		}
+	}
+	assert.True(t, sent > 0)
+	assert.True(t, replies > 0)
+
+	buf.Reset()
+	_, ok = kads[1].FindValue(hs.Key())
+	assert.True(t, ok)
+	assert.Equal(t, 0, buf.Len())
+}
@@ -28,6 +28,8 @@ const (
 	MessageType_STORE      MessageType = 2
 	MessageType_FIND_NODE  MessageType = 3
 	MessageType_FIND_VALUE MessageType = 4
+	MessageType_STORE_MANY MessageType = 5
+	MessageType_FIND_MANY  MessageType = 6
 )
 
 var MessageType_name = map[int32]string{
@@ -36,6 +38,8 @@ var MessageType_name = map[int32]string{
 	2: "STORE",
 	3: "FIND_NODE",
 	4: "FIND_VALUE",
+	5: "STORE_MANY",
+	6: "FIND_MANY",
 }
 
 var MessageType_value = map[string]int32{
@@ -44,6 +48,8 @@ var MessageType_value = map[string]int32{
 	"STORE":      2,
 	"FIND_NODE":  3,
 	"FIND_VALUE": 4,
+	"STORE_MANY": 5,
+	"FIND_MANY":  6,
 }
 
 func (x MessageType) String() string {
@@ -85,6 +91,49 @@ func (ConnectionType) EnumDescriptor() ([]byte, []int) {
 	return fileDescriptor_d938547f84707355, []int{1}
 }
 
+type RecordKind int32
+
+const (
+	RecordKind_RAW            RecordKind = 0
+	RecordKind_SHARD_MANIFEST RecordKind = 1
+	RecordKind_OBJECT_NODE    RecordKind = 2
+	RecordKind_SET_ENTRY      RecordKind = 3
+	RecordKind_SET_PAGE       RecordKind = 4
+	RecordKind_MAILBOX_ACK    RecordKind = 5
+	RecordKind_NAME           RecordKind = 6
+	RecordKind_SHARD          RecordKind = 7
+)
+
+var RecordKind_name = map[int32]string{
+	0: "RAW",
+	1: "SHARD_MANIFEST",
+	2: "OBJECT_NODE",
+	3: "SET_ENTRY",
+	4: "SET_PAGE",
+	5: "MAILBOX_ACK",
+	6: "NAME",
+	7: "SHARD",
+}
+
+var RecordKind_value = map[string]int32{
+	"RAW":            0,
+	"SHARD_MANIFEST": 1,
+	"OBJECT_NODE":    2,
+	"SET_ENTRY":      3,
+	"SET_PAGE":       4,
+	"MAILBOX_ACK":    5,
+	"NAME":           6,
+	"SHARD":          7,
+}
+
+func (x RecordKind) String() string {
+	return proto.EnumName(RecordKind_name, int32(x))
+}
+
+func (RecordKind) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_d938547f84707355, []int{2}
+}
+
 type Node struct {
 	Id                   []byte         `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Hash                 []byte         `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
@@ -156,13 +205,15 @@ func (m *Node) GetConnection() ConnectionType {
 }
 
 type Payload struct {
-	Key                  []byte   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
-	Data                 []byte   `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
-	Hash                 []byte   `protobuf:"bytes,3,opt,name=hash,proto3" json:"hash,omitempty"`
-	Sig                  []byte   `protobuf:"bytes,4,opt,name=sig,proto3" json:"sig,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Key                  []byte     `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Data                 []byte     `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Hash                 []byte     `protobuf:"bytes,3,opt,name=hash,proto3" json:"hash,omitempty"`
+	Sig                  []byte     `protobuf:"bytes,4,opt,name=sig,proto3" json:"sig,omitempty"`
+	Kind                 RecordKind `protobuf:"varint,5,opt,name=kind,proto3,enum=dht.RecordKind" json:"kind,omitempty"`
+	Expires              int64      `protobuf:"varint,6,opt,name=expires,proto3" json:"expires,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
 }
 
 func (m *Payload) Reset()         { *m = Payload{} }
@@ -217,6 +268,20 @@ func (m *Payload) GetSig() []byte {
 	return nil
 }
 
+func (m *Payload) GetKind() RecordKind {
+	if m != nil {
+		return m.Kind
+	}
+	return RecordKind_RAW
+}
+
+func (m *Payload) GetExpires() int64 {
+	if m != nil {
+		return m.Expires
+	}
+	return 0
+}
+
 type Closest struct {
 	Nodes                []*Node  `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -257,6 +322,8 @@ func (m *Closest) GetNodes() []*Node {
 
 type FindRequest struct {
 	Key                  []byte   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Page                 uint32   `protobuf:"varint,2,opt,name=page,proto3" json:"page,omitempty"`
+	Set                  bool     `protobuf:"varint,3,opt,name=set,proto3" json:"set,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -293,6 +360,20 @@ func (m *FindRequest) GetKey() []byte {
 	return nil
 }
 
+func (m *FindRequest) GetPage() uint32 {
+	if m != nil {
+		return m.Page
+	}
+	return 0
+}
+
+func (m *FindRequest) GetSet() bool {
+	if m != nil {
+		return m.Set
+	}
+	return false
+}
+
 type StoreRequest struct {
 	Payload              *Payload `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -331,20 +412,177 @@ func (m *StoreRequest) GetPayload() *Payload {
 	return nil
 }
 
+type StoreManyRequest struct {
+	Payloads             []*Payload `protobuf:"bytes,1,rep,name=payloads,proto3" json:"payloads,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
+}
+
+func (m *StoreManyRequest) Reset()         { *m = StoreManyRequest{} }
+func (m *StoreManyRequest) String() string { return proto.CompactTextString(m) }
+func (*StoreManyRequest) ProtoMessage()    {}
+func (*StoreManyRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d938547f84707355, []int{5}
+}
+func (m *StoreManyRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StoreManyRequest.Unmarshal(m, b)
+}
+func (m *StoreManyRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StoreManyRequest.Marshal(b, m, deterministic)
+}
+func (m *StoreManyRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StoreManyRequest.Merge(m, src)
+}
+func (m *StoreManyRequest) XXX_Size() int {
+	return xxx_messageInfo_StoreManyRequest.Size(m)
+}
+func (m *StoreManyRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StoreManyRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StoreManyRequest proto.InternalMessageInfo
+
+func (m *StoreManyRequest) GetPayloads() []*Payload {
+	if m != nil {
+		return m.Payloads
+	}
+	return nil
+}
+
+type FindManyRequest struct {
+	Keys                 [][]byte `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FindManyRequest) Reset()         { *m = FindManyRequest{} }
+func (m *FindManyRequest) String() string { return proto.CompactTextString(m) }
+func (*FindManyRequest) ProtoMessage()    {}
+func (*FindManyRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d938547f84707355, []int{6}
+}
+func (m *FindManyRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FindManyRequest.Unmarshal(m, b)
+}
+func (m *FindManyRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FindManyRequest.Marshal(b, m, deterministic)
+}
+func (m *FindManyRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FindManyRequest.Merge(m, src)
+}
+func (m *FindManyRequest) XXX_Size() int {
+	return xxx_messageInfo_FindManyRequest.Size(m)
+}
+func (m *FindManyRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_FindManyRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FindManyRequest proto.InternalMessageInfo
+
+func (m *FindManyRequest) GetKeys() [][]byte {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+type Payloads struct {
+	Payloads             []*Payload `protobuf:"bytes,1,rep,name=payloads,proto3" json:"payloads,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
+}
+
+func (m *Payloads) Reset()         { *m = Payloads{} }
+func (m *Payloads) String() string { return proto.CompactTextString(m) }
+func (*Payloads) ProtoMessage()    {}
+func (*Payloads) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d938547f84707355, []int{7}
+}
+func (m *Payloads) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Payloads.Unmarshal(m, b)
+}
+func (m *Payloads) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Payloads.Marshal(b, m, deterministic)
+}
+func (m *Payloads) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Payloads.Merge(m, src)
+}
+func (m *Payloads) XXX_Size() int {
+	return xxx_messageInfo_Payloads.Size(m)
+}
+func (m *Payloads) XXX_DiscardUnknown() {
+	xxx_messageInfo_Payloads.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Payloads proto.InternalMessageInfo
+
+func (m *Payloads) GetPayloads() []*Payload {
+	if m != nil {
+		return m.Payloads
+	}
+	return nil
+}
+
+type StoreManyResults struct {
+	Stored               []bool   `protobuf:"varint,1,rep,packed,name=stored,proto3" json:"stored,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StoreManyResults) Reset()         { *m = StoreManyResults{} }
+func (m *StoreManyResults) String() string { return proto.CompactTextString(m) }
+func (*StoreManyResults) ProtoMessage()    {}
+func (*StoreManyResults) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d938547f84707355, []int{8}
+}
+func (m *StoreManyResults) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StoreManyResults.Unmarshal(m, b)
+}
+func (m *StoreManyResults) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StoreManyResults.Marshal(b, m, deterministic)
+}
+func (m *StoreManyResults) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StoreManyResults.Merge(m, src)
+}
+func (m *StoreManyResults) XXX_Size() int {
+	return xxx_messageInfo_StoreManyResults.Size(m)
+}
+func (m *StoreManyResults) XXX_DiscardUnknown() {
+	xxx_messageInfo_StoreManyResults.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StoreManyResults proto.InternalMessageInfo
+
+func (m *StoreManyResults) GetStored() []bool {
+	if m != nil {
+		return m.Stored
+	}
+	return nil
+}
+
 type Message struct {
 	Id         []byte      `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Type       MessageType `protobuf:"varint,2,opt,name=type,proto3,enum=dht.MessageType" json:"type,omitempty"`
 	IsResponse bool        `protobuf:"varint,3,opt,name=is_response,json=isResponse,proto3" json:"is_response,omitempty"`
 	Sender     *Node       `protobuf:"bytes,5,opt,name=sender,proto3" json:"sender,omitempty"`
 	Receiver   *Node       `protobuf:"bytes,6,opt,name=receiver,proto3" json:"receiver,omitempty"`
+	Client     bool        `protobuf:"varint,7,opt,name=client,proto3" json:"client,omitempty"`
 	// Types that are valid to be assigned to Request:
 	//	*Message_Find
 	//	*Message_Store
+	//	*Message_StoreMany
+	//	*Message_FindMany
 	Request isMessage_Request `protobuf_oneof:"request"`
 	// Types that are valid to be assigned to Response:
 	//	*Message_Success
 	//	*Message_Payload
 	//	*Message_Closest
+	//	*Message_Values
+	//	*Message_Stored
 	Response             isMessage_Response `protobuf_oneof:"response"`
 	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
 	XXX_unrecognized     []byte             `json:"-"`
@@ -355,7 +593,7 @@ func (m *Message) Reset()         { *m = Message{} }
 func (m *Message) String() string { return proto.CompactTextString(m) }
 func (*Message) ProtoMessage()    {}
 func (*Message) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d938547f84707355, []int{5}
+	return fileDescriptor_d938547f84707355, []int{9}
 }
 func (m *Message) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Message.Unmarshal(m, b)
@@ -388,6 +626,12 @@ type Message_Find struct {
 type Message_Store struct {
 	Store *StoreRequest `protobuf:"bytes,11,opt,name=store,proto3,oneof" json:"store,omitempty"`
 }
+type Message_StoreMany struct {
+	StoreMany *StoreManyRequest `protobuf:"bytes,12,opt,name=store_many,json=storeMany,proto3,oneof" json:"store_many,omitempty"`
+}
+type Message_FindMany struct {
+	FindMany *FindManyRequest `protobuf:"bytes,13,opt,name=find_many,json=findMany,proto3,oneof" json:"find_many,omitempty"`
+}
 type Message_Success struct {
 	Success bool `protobuf:"varint,20,opt,name=success,proto3,oneof" json:"success,omitempty"`
 }
@@ -397,12 +641,22 @@ type Message_Payload struct {
 type Message_Closest struct {
 	Closest *Closest `protobuf:"bytes,22,opt,name=closest,proto3,oneof" json:"closest,omitempty"`
 }
+type Message_Values struct {
+	Values *Payloads `protobuf:"bytes,23,opt,name=values,proto3,oneof" json:"values,omitempty"`
+}
+type Message_Stored struct {
+	Stored *StoreManyResults `protobuf:"bytes,24,opt,name=stored,proto3,oneof" json:"stored,omitempty"`
+}
 
-func (*Message_Find) isMessage_Request()     {}
-func (*Message_Store) isMessage_Request()    {}
-func (*Message_Success) isMessage_Response() {}
-func (*Message_Payload) isMessage_Response() {}
-func (*Message_Closest) isMessage_Response() {}
+func (*Message_Find) isMessage_Request()      {}
+func (*Message_Store) isMessage_Request()     {}
+func (*Message_StoreMany) isMessage_Request() {}
+func (*Message_FindMany) isMessage_Request()  {}
+func (*Message_Success) isMessage_Response()  {}
+func (*Message_Payload) isMessage_Response()  {}
+func (*Message_Closest) isMessage_Response()  {}
+func (*Message_Values) isMessage_Response()   {}
+func (*Message_Stored) isMessage_Response()   {}
 
 func (m *Message) GetRequest() isMessage_Request {
 	if m != nil {
@@ -452,6 +706,13 @@ func (m *Message) GetReceiver() *Node {
 	return nil
 }
 
+func (m *Message) GetClient() bool {
+	if m != nil {
+		return m.Client
+	}
+	return false
+}
+
 func (m *Message) GetFind() *FindRequest {
 	if x, ok := m.GetRequest().(*Message_Find); ok {
 		return x.Find
@@ -466,6 +727,20 @@ func (m *Message) GetStore() *StoreRequest {
 	return nil
 }
 
+func (m *Message) GetStoreMany() *StoreManyRequest {
+	if x, ok := m.GetRequest().(*Message_StoreMany); ok {
+		return x.StoreMany
+	}
+	return nil
+}
+
+func (m *Message) GetFindMany() *FindManyRequest {
+	if x, ok := m.GetRequest().(*Message_FindMany); ok {
+		return x.FindMany
+	}
+	return nil
+}
+
 func (m *Message) GetSuccess() bool {
 	if x, ok := m.GetResponse().(*Message_Success); ok {
 		return x.Success
@@ -487,64 +762,738 @@ func (m *Message) GetClosest() *Closest {
 	return nil
 }
 
+func (m *Message) GetValues() *Payloads {
+	if x, ok := m.GetResponse().(*Message_Values); ok {
+		return x.Values
+	}
+	return nil
+}
+
+func (m *Message) GetStored() *StoreManyResults {
+	if x, ok := m.GetResponse().(*Message_Stored); ok {
+		return x.Stored
+	}
+	return nil
+}
+
 // XXX_OneofWrappers is for the internal use of the proto package.
 func (*Message) XXX_OneofWrappers() []interface{} {
 	return []interface{}{
 		(*Message_Find)(nil),
 		(*Message_Store)(nil),
+		(*Message_StoreMany)(nil),
+		(*Message_FindMany)(nil),
 		(*Message_Success)(nil),
 		(*Message_Payload)(nil),
 		(*Message_Closest)(nil),
+		(*Message_Values)(nil),
+		(*Message_Stored)(nil),
+	}
+}
+
+type ShardManifest struct {
+	Key                  []byte   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Hash                 []byte   `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	Length               uint64   `protobuf:"varint,3,opt,name=length,proto3" json:"length,omitempty"`
+	DataShards           uint32   `protobuf:"varint,4,opt,name=data_shards,json=dataShards,proto3" json:"data_shards,omitempty"`
+	ParityShards         uint32   `protobuf:"varint,5,opt,name=parity_shards,json=parityShards,proto3" json:"parity_shards,omitempty"`
+	Shards               [][]byte `protobuf:"bytes,6,rep,name=shards,proto3" json:"shards,omitempty"`
+	PublicKey            []byte   `protobuf:"bytes,7,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Sig                  []byte   `protobuf:"bytes,8,opt,name=sig,proto3" json:"sig,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ShardManifest) Reset()         { *m = ShardManifest{} }
+func (m *ShardManifest) String() string { return proto.CompactTextString(m) }
+func (*ShardManifest) ProtoMessage()    {}
+func (*ShardManifest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d938547f84707355, []int{10}
+}
+func (m *ShardManifest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ShardManifest.Unmarshal(m, b)
+}
+func (m *ShardManifest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ShardManifest.Marshal(b, m, deterministic)
+}
+func (m *ShardManifest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ShardManifest.Merge(m, src)
+}
+func (m *ShardManifest) XXX_Size() int {
+	return xxx_messageInfo_ShardManifest.Size(m)
+}
+func (m *ShardManifest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ShardManifest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ShardManifest proto.InternalMessageInfo
+
+func (m *ShardManifest) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *ShardManifest) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+func (m *ShardManifest) GetLength() uint64 {
+	if m != nil {
+		return m.Length
+	}
+	return 0
+}
+
+func (m *ShardManifest) GetDataShards() uint32 {
+	if m != nil {
+		return m.DataShards
+	}
+	return 0
+}
+
+func (m *ShardManifest) GetParityShards() uint32 {
+	if m != nil {
+		return m.ParityShards
+	}
+	return 0
+}
+
+func (m *ShardManifest) GetShards() [][]byte {
+	if m != nil {
+		return m.Shards
+	}
+	return nil
+}
+
+func (m *ShardManifest) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *ShardManifest) GetSig() []byte {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+type ObjectLink struct {
+	Hash                 []byte   `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Length               uint64   `protobuf:"varint,2,opt,name=length,proto3" json:"length,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ObjectLink) Reset()         { *m = ObjectLink{} }
+func (m *ObjectLink) String() string { return proto.CompactTextString(m) }
+func (*ObjectLink) ProtoMessage()    {}
+func (*ObjectLink) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d938547f84707355, []int{11}
+}
+func (m *ObjectLink) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ObjectLink.Unmarshal(m, b)
+}
+func (m *ObjectLink) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ObjectLink.Marshal(b, m, deterministic)
+}
+func (m *ObjectLink) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ObjectLink.Merge(m, src)
+}
+func (m *ObjectLink) XXX_Size() int {
+	return xxx_messageInfo_ObjectLink.Size(m)
+}
+func (m *ObjectLink) XXX_DiscardUnknown() {
+	xxx_messageInfo_ObjectLink.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ObjectLink proto.InternalMessageInfo
+
+func (m *ObjectLink) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+func (m *ObjectLink) GetLength() uint64 {
+	if m != nil {
+		return m.Length
+	}
+	return 0
+}
+
+type ObjectNode struct {
+	Depth                uint32        `protobuf:"varint,1,opt,name=depth,proto3" json:"depth,omitempty"`
+	Length               uint64        `protobuf:"varint,2,opt,name=length,proto3" json:"length,omitempty"`
+	Links                []*ObjectLink `protobuf:"bytes,3,rep,name=links,proto3" json:"links,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *ObjectNode) Reset()         { *m = ObjectNode{} }
+func (m *ObjectNode) String() string { return proto.CompactTextString(m) }
+func (*ObjectNode) ProtoMessage()    {}
+func (*ObjectNode) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d938547f84707355, []int{12}
+}
+func (m *ObjectNode) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ObjectNode.Unmarshal(m, b)
+}
+func (m *ObjectNode) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ObjectNode.Marshal(b, m, deterministic)
+}
+func (m *ObjectNode) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ObjectNode.Merge(m, src)
+}
+func (m *ObjectNode) XXX_Size() int {
+	return xxx_messageInfo_ObjectNode.Size(m)
+}
+func (m *ObjectNode) XXX_DiscardUnknown() {
+	xxx_messageInfo_ObjectNode.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ObjectNode proto.InternalMessageInfo
+
+func (m *ObjectNode) GetDepth() uint32 {
+	if m != nil {
+		return m.Depth
+	}
+	return 0
+}
+
+func (m *ObjectNode) GetLength() uint64 {
+	if m != nil {
+		return m.Length
 	}
+	return 0
+}
+
+func (m *ObjectNode) GetLinks() []*ObjectLink {
+	if m != nil {
+		return m.Links
+	}
+	return nil
+}
+
+type SetEntry struct {
+	Set                  []byte   `protobuf:"bytes,1,opt,name=set,proto3" json:"set,omitempty"`
+	Data                 []byte   `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Expires              int64    `protobuf:"varint,3,opt,name=expires,proto3" json:"expires,omitempty"`
+	PublicKey            []byte   `protobuf:"bytes,4,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Sig                  []byte   `protobuf:"bytes,5,opt,name=sig,proto3" json:"sig,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetEntry) Reset()         { *m = SetEntry{} }
+func (m *SetEntry) String() string { return proto.CompactTextString(m) }
+func (*SetEntry) ProtoMessage()    {}
+func (*SetEntry) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d938547f84707355, []int{13}
+}
+func (m *SetEntry) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetEntry.Unmarshal(m, b)
+}
+func (m *SetEntry) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetEntry.Marshal(b, m, deterministic)
+}
+func (m *SetEntry) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetEntry.Merge(m, src)
+}
+func (m *SetEntry) XXX_Size() int {
+	return xxx_messageInfo_SetEntry.Size(m)
+}
+func (m *SetEntry) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetEntry.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetEntry proto.InternalMessageInfo
+
+func (m *SetEntry) GetSet() []byte {
+	if m != nil {
+		return m.Set
+	}
+	return nil
+}
+
+func (m *SetEntry) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *SetEntry) GetExpires() int64 {
+	if m != nil {
+		return m.Expires
+	}
+	return 0
+}
+
+func (m *SetEntry) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *SetEntry) GetSig() []byte {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+type SetPage struct {
+	Set                  []byte      `protobuf:"bytes,1,opt,name=set,proto3" json:"set,omitempty"`
+	Entries              []*SetEntry `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries,omitempty"`
+	Page                 uint32      `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"`
+	More                 bool        `protobuf:"varint,4,opt,name=more,proto3" json:"more,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *SetPage) Reset()         { *m = SetPage{} }
+func (m *SetPage) String() string { return proto.CompactTextString(m) }
+func (*SetPage) ProtoMessage()    {}
+func (*SetPage) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d938547f84707355, []int{14}
+}
+func (m *SetPage) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetPage.Unmarshal(m, b)
+}
+func (m *SetPage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetPage.Marshal(b, m, deterministic)
+}
+func (m *SetPage) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetPage.Merge(m, src)
+}
+func (m *SetPage) XXX_Size() int {
+	return xxx_messageInfo_SetPage.Size(m)
+}
+func (m *SetPage) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetPage.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetPage proto.InternalMessageInfo
+
+func (m *SetPage) GetSet() []byte {
+	if m != nil {
+		return m.Set
+	}
+	return nil
+}
+
+func (m *SetPage) GetEntries() []*SetEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+func (m *SetPage) GetPage() uint32 {
+	if m != nil {
+		return m.Page
+	}
+	return 0
+}
+
+func (m *SetPage) GetMore() bool {
+	if m != nil {
+		return m.More
+	}
+	return false
+}
+
+type MailEnvelope struct {
+	From                 []byte   `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	To                   []byte   `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	Data                 []byte   `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	Sent                 int64    `protobuf:"varint,4,opt,name=sent,proto3" json:"sent,omitempty"`
+	Sig                  []byte   `protobuf:"bytes,5,opt,name=sig,proto3" json:"sig,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MailEnvelope) Reset()         { *m = MailEnvelope{} }
+func (m *MailEnvelope) String() string { return proto.CompactTextString(m) }
+func (*MailEnvelope) ProtoMessage()    {}
+func (*MailEnvelope) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d938547f84707355, []int{15}
+}
+func (m *MailEnvelope) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MailEnvelope.Unmarshal(m, b)
+}
+func (m *MailEnvelope) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MailEnvelope.Marshal(b, m, deterministic)
+}
+func (m *MailEnvelope) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MailEnvelope.Merge(m, src)
+}
+func (m *MailEnvelope) XXX_Size() int {
+	return xxx_messageInfo_MailEnvelope.Size(m)
+}
+func (m *MailEnvelope) XXX_DiscardUnknown() {
+	xxx_messageInfo_MailEnvelope.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MailEnvelope proto.InternalMessageInfo
+
+func (m *MailEnvelope) GetFrom() []byte {
+	if m != nil {
+		return m.From
+	}
+	return nil
+}
+
+func (m *MailEnvelope) GetTo() []byte {
+	if m != nil {
+		return m.To
+	}
+	return nil
+}
+
+func (m *MailEnvelope) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *MailEnvelope) GetSent() int64 {
+	if m != nil {
+		return m.Sent
+	}
+	return 0
+}
+
+func (m *MailEnvelope) GetSig() []byte {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+type SealedMail struct {
+	Ephemeral            []byte   `protobuf:"bytes,1,opt,name=ephemeral,proto3" json:"ephemeral,omitempty"`
+	Nonce                []byte   `protobuf:"bytes,2,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Box                  []byte   `protobuf:"bytes,3,opt,name=box,proto3" json:"box,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SealedMail) Reset()         { *m = SealedMail{} }
+func (m *SealedMail) String() string { return proto.CompactTextString(m) }
+func (*SealedMail) ProtoMessage()    {}
+func (*SealedMail) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d938547f84707355, []int{16}
+}
+func (m *SealedMail) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SealedMail.Unmarshal(m, b)
+}
+func (m *SealedMail) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SealedMail.Marshal(b, m, deterministic)
+}
+func (m *SealedMail) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SealedMail.Merge(m, src)
+}
+func (m *SealedMail) XXX_Size() int {
+	return xxx_messageInfo_SealedMail.Size(m)
+}
+func (m *SealedMail) XXX_DiscardUnknown() {
+	xxx_messageInfo_SealedMail.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SealedMail proto.InternalMessageInfo
+
+func (m *SealedMail) GetEphemeral() []byte {
+	if m != nil {
+		return m.Ephemeral
+	}
+	return nil
+}
+
+func (m *SealedMail) GetNonce() []byte {
+	if m != nil {
+		return m.Nonce
+	}
+	return nil
+}
+
+func (m *SealedMail) GetBox() []byte {
+	if m != nil {
+		return m.Box
+	}
+	return nil
+}
+
+type MailboxAck struct {
+	Mailbox              []byte   `protobuf:"bytes,1,opt,name=mailbox,proto3" json:"mailbox,omitempty"`
+	Ids                  [][]byte `protobuf:"bytes,2,rep,name=ids,proto3" json:"ids,omitempty"`
+	PublicKey            []byte   `protobuf:"bytes,3,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Sig                  []byte   `protobuf:"bytes,4,opt,name=sig,proto3" json:"sig,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MailboxAck) Reset()         { *m = MailboxAck{} }
+func (m *MailboxAck) String() string { return proto.CompactTextString(m) }
+func (*MailboxAck) ProtoMessage()    {}
+func (*MailboxAck) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d938547f84707355, []int{17}
+}
+func (m *MailboxAck) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MailboxAck.Unmarshal(m, b)
+}
+func (m *MailboxAck) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MailboxAck.Marshal(b, m, deterministic)
+}
+func (m *MailboxAck) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MailboxAck.Merge(m, src)
+}
+func (m *MailboxAck) XXX_Size() int {
+	return xxx_messageInfo_MailboxAck.Size(m)
+}
+func (m *MailboxAck) XXX_DiscardUnknown() {
+	xxx_messageInfo_MailboxAck.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MailboxAck proto.InternalMessageInfo
+
+func (m *MailboxAck) GetMailbox() []byte {
+	if m != nil {
+		return m.Mailbox
+	}
+	return nil
+}
+
+func (m *MailboxAck) GetIds() [][]byte {
+	if m != nil {
+		return m.Ids
+	}
+	return nil
+}
+
+func (m *MailboxAck) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *MailboxAck) GetSig() []byte {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+type NameRecord struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	PeerId               []byte   `protobuf:"bytes,2,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
+	Addrs                [][]byte `protobuf:"bytes,3,rep,name=addrs,proto3" json:"addrs,omitempty"`
+	Expires              int64    `protobuf:"varint,4,opt,name=expires,proto3" json:"expires,omitempty"`
+	Seq                  uint64   `protobuf:"varint,5,opt,name=seq,proto3" json:"seq,omitempty"`
+	PublicKey            []byte   `protobuf:"bytes,6,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Sig                  []byte   `protobuf:"bytes,7,opt,name=sig,proto3" json:"sig,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *NameRecord) Reset()         { *m = NameRecord{} }
+func (m *NameRecord) String() string { return proto.CompactTextString(m) }
+func (*NameRecord) ProtoMessage()    {}
+func (*NameRecord) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d938547f84707355, []int{18}
+}
+func (m *NameRecord) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_NameRecord.Unmarshal(m, b)
+}
+func (m *NameRecord) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_NameRecord.Marshal(b, m, deterministic)
+}
+func (m *NameRecord) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_NameRecord.Merge(m, src)
+}
+func (m *NameRecord) XXX_Size() int {
+	return xxx_messageInfo_NameRecord.Size(m)
+}
+func (m *NameRecord) XXX_DiscardUnknown() {
+	xxx_messageInfo_NameRecord.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_NameRecord proto.InternalMessageInfo
+
+func (m *NameRecord) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *NameRecord) GetPeerId() []byte {
+	if m != nil {
+		return m.PeerId
+	}
+	return nil
+}
+
+func (m *NameRecord) GetAddrs() [][]byte {
+	if m != nil {
+		return m.Addrs
+	}
+	return nil
+}
+
+func (m *NameRecord) GetExpires() int64 {
+	if m != nil {
+		return m.Expires
+	}
+	return 0
+}
+
+func (m *NameRecord) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *NameRecord) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *NameRecord) GetSig() []byte {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
 }
 
 func init() {
 	proto.RegisterEnum("dht.MessageType", MessageType_name, MessageType_value)
 	proto.RegisterEnum("dht.ConnectionType", ConnectionType_name, ConnectionType_value)
+	proto.RegisterEnum("dht.RecordKind", RecordKind_name, RecordKind_value)
 	proto.RegisterType((*Node)(nil), "dht.Node")
 	proto.RegisterType((*Payload)(nil), "dht.Payload")
 	proto.RegisterType((*Closest)(nil), "dht.Closest")
 	proto.RegisterType((*FindRequest)(nil), "dht.FindRequest")
 	proto.RegisterType((*StoreRequest)(nil), "dht.StoreRequest")
+	proto.RegisterType((*StoreManyRequest)(nil), "dht.StoreManyRequest")
+	proto.RegisterType((*FindManyRequest)(nil), "dht.FindManyRequest")
+	proto.RegisterType((*Payloads)(nil), "dht.Payloads")
+	proto.RegisterType((*StoreManyResults)(nil), "dht.StoreManyResults")
 	proto.RegisterType((*Message)(nil), "dht.Message")
+	proto.RegisterType((*ShardManifest)(nil), "dht.ShardManifest")
+	proto.RegisterType((*ObjectLink)(nil), "dht.ObjectLink")
+	proto.RegisterType((*ObjectNode)(nil), "dht.ObjectNode")
+	proto.RegisterType((*SetEntry)(nil), "dht.SetEntry")
+	proto.RegisterType((*SetPage)(nil), "dht.SetPage")
+	proto.RegisterType((*MailEnvelope)(nil), "dht.MailEnvelope")
+	proto.RegisterType((*SealedMail)(nil), "dht.SealedMail")
+	proto.RegisterType((*MailboxAck)(nil), "dht.MailboxAck")
+	proto.RegisterType((*NameRecord)(nil), "dht.NameRecord")
 }
 
 func init() { proto.RegisterFile("types.proto", fileDescriptor_d938547f84707355) }
 
 var fileDescriptor_d938547f84707355 = []byte{
-	// 537 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x64, 0x93, 0x4f, 0x6f, 0xda, 0x40,
-	0x10, 0xc5, 0x31, 0x36, 0x18, 0xc6, 0xc4, 0x75, 0xa6, 0x69, 0xbb, 0xea, 0x05, 0x6a, 0xb5, 0x11,
-	0xe5, 0xc0, 0x81, 0x48, 0xbd, 0xf3, 0x2f, 0x05, 0x35, 0xb5, 0xd1, 0x86, 0xb4, 0x47, 0xe4, 0x7a,
-	0xb7, 0x60, 0x35, 0xb2, 0x5d, 0xaf, 0x53, 0x89, 0x7b, 0xcf, 0xfd, 0xcc, 0xd5, 0xae, 0x0d, 0x71,
-	0xc2, 0x6d, 0x66, 0xde, 0x6f, 0x97, 0x37, 0x6f, 0x31, 0x58, 0xf9, 0x3e, 0xe5, 0x62, 0x98, 0x66,
-	0x49, 0x9e, 0xa0, 0xce, 0x76, 0xb9, 0xfb, 0x4f, 0x03, 0xc3, 0x4b, 0x18, 0x47, 0x1b, 0xea, 0x11,
-	0x23, 0x5a, 0x4f, 0xeb, 0x77, 0x68, 0x3d, 0x62, 0x88, 0x60, 0xec, 0x02, 0xb1, 0x23, 0x75, 0x35,
-	0x51, 0x35, 0xbe, 0x01, 0x33, 0xe5, 0x3c, 0xdb, 0x44, 0x8c, 0xe8, 0x6a, 0xdc, 0x94, 0xed, 0x92,
-	0xe1, 0x05, 0x34, 0x02, 0xc6, 0x32, 0x41, 0x8c, 0x9e, 0xde, 0xef, 0xd0, 0xa2, 0xc1, 0x2b, 0x80,
-	0x30, 0x89, 0x63, 0x1e, 0xe6, 0x51, 0x12, 0x93, 0x46, 0x4f, 0xeb, 0xdb, 0xa3, 0x97, 0x43, 0xb6,
-	0xcb, 0x87, 0xd3, 0xe3, 0x78, 0xbd, 0x4f, 0x39, 0xad, 0x60, 0xee, 0x1d, 0x98, 0xab, 0x60, 0x7f,
-	0x9f, 0x04, 0x0c, 0x1d, 0xd0, 0x7f, 0xf1, 0x7d, 0xe9, 0x49, 0x96, 0xd2, 0x14, 0x0b, 0xf2, 0xe0,
-	0x60, 0x4a, 0xd6, 0x47, 0xa3, 0x7a, 0xc5, 0xa8, 0x03, 0xba, 0x88, 0xb6, 0xc4, 0x28, 0x4e, 0x8a,
-	0x68, 0xeb, 0x0e, 0xc0, 0x9c, 0xde, 0x27, 0x82, 0x8b, 0x1c, 0xbb, 0xd0, 0x88, 0x13, 0xc6, 0x05,
-	0xd1, 0x7a, 0x7a, 0xdf, 0x1a, 0xb5, 0x95, 0x23, 0x99, 0x01, 0x2d, 0xe6, 0x6e, 0x17, 0xac, 0xeb,
-	0x28, 0x66, 0x94, 0xff, 0x7e, 0x90, 0xfc, 0x89, 0x0d, 0xf7, 0x13, 0x74, 0x6e, 0xf3, 0x24, 0xe3,
-	0x07, 0xe2, 0x12, 0xcc, 0xb4, 0xf0, 0xac, 0x28, 0x6b, 0xd4, 0x51, 0x77, 0x96, 0x7b, 0xd0, 0x83,
-	0xe8, 0xfe, 0xd5, 0xc1, 0xfc, 0xca, 0x85, 0x08, 0xb6, 0xa7, 0x79, 0xbf, 0x07, 0x43, 0x3e, 0x8e,
-	0x5a, 0xcd, 0x1e, 0x39, 0xea, 0x82, 0x92, 0x55, 0x19, 0x29, 0x15, 0xbb, 0x60, 0x45, 0x62, 0x93,
-	0x71, 0x91, 0x26, 0xb1, 0xe0, 0x6a, 0xe7, 0x16, 0x85, 0x48, 0xd0, 0x72, 0x82, 0xef, 0xa0, 0x29,
-	0x78, 0xcc, 0x78, 0xa6, 0xf2, 0x7e, 0xb2, 0x5d, 0x29, 0xe0, 0x07, 0x68, 0x65, 0x3c, 0xe4, 0xd1,
-	0x1f, 0x9e, 0x91, 0xe6, 0x73, 0xe8, 0x28, 0xe1, 0x25, 0x18, 0x3f, 0xa3, 0x98, 0x11, 0x50, 0x48,
-	0x61, 0xa8, 0x12, 0xcb, 0xa2, 0x46, 0x95, 0x8e, 0x1f, 0xa1, 0x21, 0x64, 0x18, 0xc4, 0x52, 0xe0,
-	0xb9, 0x02, 0xab, 0xf1, 0x2c, 0x6a, 0xb4, 0x20, 0xf0, 0x2d, 0x98, 0xe2, 0x21, 0x0c, 0xb9, 0x10,
-	0xe4, 0x42, 0x3a, 0x5f, 0x68, 0xf4, 0x30, 0xc0, 0xfe, 0x63, 0x86, 0xaf, 0x4e, 0x33, 0x94, 0x64,
-	0x29, 0x4b, 0x32, 0x2c, 0x9e, 0x92, 0xbc, 0xae, 0x90, 0xe5, 0xf3, 0x4a, 0xb2, 0x94, 0x27, 0x6d,
-	0x30, 0xb3, 0xc2, 0xc3, 0x04, 0xe4, 0xd2, 0x45, 0x46, 0x83, 0x2f, 0x60, 0x55, 0x92, 0xc5, 0x16,
-	0x18, 0x9e, 0xef, 0xaf, 0x9c, 0x9a, 0xac, 0x56, 0x4b, 0xef, 0xb3, 0xa3, 0x61, 0x1b, 0x1a, 0xb7,
-	0x6b, 0x9f, 0xce, 0x9d, 0x3a, 0x9e, 0x41, 0xfb, 0x7a, 0xe9, 0xcd, 0x36, 0x9e, 0x3f, 0x9b, 0x3b,
-	0x3a, 0xda, 0x00, 0xaa, 0xfd, 0x36, 0xbe, 0xb9, 0x9b, 0x3b, 0xc6, 0xe0, 0x3b, 0xd8, 0x4f, 0xff,
-	0xcd, 0x78, 0x0e, 0x67, 0x9e, 0xbf, 0xde, 0x4c, 0x7d, 0xcf, 0x9b, 0x4f, 0xd7, 0xf3, 0x99, 0x53,
-	0x93, 0x77, 0x3c, 0xb6, 0x1a, 0xbe, 0x00, 0xab, 0x6c, 0xc7, 0x93, 0x1b, 0xf9, 0x1b, 0x08, 0xf6,
-	0x74, 0xec, 0x55, 0x4e, 0x39, 0xfa, 0x8f, 0xa6, 0xfa, 0x4a, 0xaf, 0xfe, 0x07, 0x00, 0x00, 0xff,
-	0xff, 0x1c, 0x73, 0x84, 0x8f, 0xb4, 0x03, 0x00, 0x00,
+	// 1234 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x56, 0xcd, 0x6e, 0xdb, 0x46,
+	0x10, 0x36, 0x45, 0x4a, 0x94, 0x46, 0x92, 0xcd, 0x6c, 0x9d, 0x84, 0x28, 0x5a, 0xc4, 0x65, 0x9a,
+	0x44, 0xf5, 0x21, 0x05, 0x9c, 0x22, 0xe8, 0xa1, 0x17, 0x59, 0x51, 0x62, 0x37, 0xb6, 0x64, 0xac,
+	0xd4, 0xa6, 0x39, 0x09, 0xb4, 0xb8, 0xb6, 0x19, 0x53, 0x24, 0xc3, 0xa5, 0x83, 0x08, 0x45, 0x5f,
+	0xa1, 0xa7, 0x3e, 0x46, 0x1f, 0xa9, 0xb7, 0xbe, 0x48, 0x31, 0xb3, 0x4b, 0x8a, 0xf2, 0xcf, 0xa1,
+	0xb7, 0xf9, 0xf9, 0xe6, 0x7f, 0x38, 0x5c, 0x68, 0xe7, 0xcb, 0x54, 0xc8, 0xe7, 0x69, 0x96, 0xe4,
+	0x09, 0x33, 0x83, 0x8b, 0xdc, 0xfb, 0xd3, 0x00, 0x6b, 0x94, 0x04, 0x82, 0x6d, 0x42, 0x2d, 0x0c,
+	0x5c, 0x63, 0xc7, 0xe8, 0x75, 0x78, 0x2d, 0x0c, 0x18, 0x03, 0xeb, 0xc2, 0x97, 0x17, 0x6e, 0x8d,
+	0x24, 0x44, 0xb3, 0x87, 0x60, 0xa7, 0x42, 0x64, 0xb3, 0x30, 0x70, 0x4d, 0x12, 0x37, 0x90, 0x3d,
+	0x0c, 0xd8, 0x36, 0xd4, 0xfd, 0x20, 0xc8, 0xa4, 0x6b, 0xed, 0x98, 0xbd, 0x0e, 0x57, 0x0c, 0x7b,
+	0x01, 0x30, 0x4f, 0xe2, 0x58, 0xcc, 0xf3, 0x30, 0x89, 0xdd, 0xfa, 0x8e, 0xd1, 0xdb, 0xdc, 0xfb,
+	0xe2, 0x79, 0x70, 0x91, 0x3f, 0x1f, 0x94, 0xe2, 0xe9, 0x32, 0x15, 0xbc, 0x02, 0xf3, 0xfe, 0x32,
+	0xc0, 0x3e, 0xf1, 0x97, 0x51, 0xe2, 0x07, 0xcc, 0x01, 0xf3, 0x52, 0x2c, 0x75, 0x52, 0x48, 0x62,
+	0x56, 0x81, 0x9f, 0xfb, 0x45, 0x56, 0x48, 0x97, 0x99, 0x9a, 0x95, 0x4c, 0x1d, 0x30, 0x65, 0x78,
+	0xee, 0x5a, 0xca, 0x52, 0x86, 0xe7, 0xec, 0x31, 0x58, 0x97, 0x61, 0x1c, 0xe8, 0x34, 0xb6, 0x28,
+	0x0d, 0x2e, 0xe6, 0x49, 0x16, 0xbc, 0x0d, 0xe3, 0x80, 0x93, 0x92, 0xb9, 0x60, 0x8b, 0xcf, 0x69,
+	0x98, 0x09, 0xe9, 0x36, 0x76, 0x8c, 0x9e, 0xc9, 0x0b, 0xd6, 0xdb, 0x05, 0x7b, 0x10, 0x25, 0x52,
+	0xc8, 0x9c, 0x3d, 0x82, 0x7a, 0x9c, 0x04, 0x42, 0xba, 0xc6, 0x8e, 0xd9, 0x6b, 0xef, 0xb5, 0xc8,
+	0x15, 0xf6, 0x90, 0x2b, 0xb9, 0x37, 0x84, 0xf6, 0x6b, 0xf4, 0x29, 0x3e, 0x5e, 0x21, 0xfe, 0xd6,
+	0x2a, 0x52, 0xff, 0x5c, 0x50, 0x15, 0x5d, 0x4e, 0x34, 0x65, 0x2c, 0x72, 0x2a, 0xa2, 0xc9, 0x91,
+	0xf4, 0x5e, 0x42, 0x67, 0x92, 0x27, 0x99, 0x28, 0xfc, 0x3c, 0x05, 0x3b, 0x55, 0x8d, 0x21, 0x5f,
+	0xed, 0xbd, 0x0e, 0x45, 0xd6, 0xcd, 0xe2, 0x85, 0xd2, 0xfb, 0x09, 0x1c, 0xb2, 0x3b, 0xf6, 0xe3,
+	0x65, 0x61, 0xdb, 0x83, 0xa6, 0x56, 0x17, 0x69, 0xaf, 0x1b, 0x97, 0x5a, 0xef, 0x09, 0x6c, 0x61,
+	0xf2, 0x55, 0x63, 0x06, 0xd6, 0xa5, 0x58, 0x2a, 0xc3, 0x0e, 0x27, 0xda, 0xfb, 0x01, 0x9a, 0xda,
+	0x56, 0xfe, 0x0f, 0xe7, 0xbb, 0x6b, 0xa9, 0xc9, 0xab, 0x28, 0x97, 0xec, 0x01, 0x34, 0x24, 0xca,
+	0x02, 0xb2, 0x6d, 0x72, 0xcd, 0x79, 0xff, 0x5a, 0x60, 0x1f, 0x0b, 0x29, 0xfd, 0xf3, 0x9b, 0xcb,
+	0xf9, 0x2d, 0x58, 0xb8, 0xc9, 0xd4, 0xc0, 0xcd, 0x3d, 0x87, 0xa2, 0x69, 0x2c, 0x2d, 0x14, 0x69,
+	0xd9, 0x23, 0x68, 0x87, 0x72, 0x96, 0x09, 0x99, 0x26, 0xb1, 0x14, 0xba, 0xb5, 0x10, 0x4a, 0xae,
+	0x25, 0xec, 0x1b, 0x68, 0x48, 0x11, 0x07, 0x22, 0xa3, 0xad, 0x58, 0x1b, 0xa5, 0x56, 0xb0, 0x27,
+	0xd0, 0xcc, 0xc4, 0x5c, 0x84, 0x9f, 0x44, 0xe6, 0x36, 0xae, 0x83, 0x4a, 0x15, 0x16, 0x31, 0x8f,
+	0x42, 0x11, 0xe7, 0xae, 0x4d, 0x51, 0x34, 0xc7, 0x9e, 0x82, 0x75, 0x86, 0x5b, 0x07, 0x64, 0xaa,
+	0x12, 0xad, 0xec, 0xc6, 0xc1, 0x06, 0x27, 0x3d, 0xfb, 0x0e, 0xea, 0x54, 0xb6, 0xdb, 0x26, 0xe0,
+	0x3d, 0x02, 0x56, 0xa7, 0x7f, 0xb0, 0xc1, 0x15, 0x82, 0xbd, 0x04, 0x20, 0x62, 0xb6, 0xf0, 0xe3,
+	0xa5, 0xdb, 0x21, 0xfc, 0xfd, 0x15, 0xbe, 0x32, 0xb8, 0x83, 0x0d, 0xde, 0x92, 0x85, 0x8c, 0xbd,
+	0x80, 0x16, 0x86, 0x52, 0x66, 0x5d, 0x32, 0xdb, 0x2e, 0xf3, 0x59, 0xb7, 0x6a, 0x9e, 0x69, 0x11,
+	0xfb, 0x12, 0x6c, 0x79, 0x35, 0x9f, 0x0b, 0x29, 0xdd, 0x6d, 0x2c, 0xec, 0xc0, 0xe0, 0x85, 0x80,
+	0xf5, 0x56, 0xfb, 0x78, 0xff, 0xe6, 0x3e, 0x22, 0x52, 0xab, 0x11, 0x39, 0x57, 0x1f, 0x8f, 0xfb,
+	0xa0, 0x82, 0xd4, 0x1f, 0x14, 0x22, 0xb5, 0x9a, 0x3d, 0x83, 0xc6, 0x27, 0x3f, 0xba, 0x12, 0xd2,
+	0x7d, 0x48, 0xc0, 0x6e, 0xd5, 0xa5, 0x3c, 0x30, 0xb8, 0x56, 0xb3, 0xef, 0xcb, 0xad, 0x71, 0x6f,
+	0xef, 0x00, 0x2d, 0x17, 0x1a, 0x28, 0xd8, 0x7e, 0x0b, 0xec, 0x4c, 0x15, 0xb8, 0x0f, 0x38, 0x53,
+	0xb5, 0x02, 0xde, 0x3f, 0x06, 0x74, 0x27, 0x17, 0x7e, 0x86, 0xe5, 0x86, 0x67, 0x77, 0x7e, 0xae,
+	0x37, 0x4e, 0xe1, 0x03, 0x68, 0x44, 0x22, 0x3e, 0xcf, 0xd5, 0xd9, 0xb1, 0xb8, 0xe6, 0x70, 0xe7,
+	0xf0, 0x28, 0xcd, 0x24, 0xfa, 0x94, 0x74, 0x80, 0xba, 0x1c, 0x50, 0x44, 0x51, 0x24, 0x7b, 0x0c,
+	0xdd, 0xd4, 0xcf, 0xc2, 0x7c, 0x59, 0x40, 0xea, 0x04, 0xe9, 0x28, 0xa1, 0x06, 0xe1, 0x37, 0xa1,
+	0xb4, 0x0d, 0xfa, 0xe6, 0x34, 0xc7, 0xbe, 0x06, 0x48, 0xaf, 0x4e, 0xa3, 0x70, 0x3e, 0xc3, 0x14,
+	0x6d, 0xca, 0xa7, 0xa5, 0x24, 0x6f, 0xc5, 0xb2, 0xb8, 0x7a, 0xcd, 0xf2, 0xea, 0x79, 0x3f, 0x02,
+	0x8c, 0x4f, 0x3f, 0x88, 0x79, 0x7e, 0x14, 0xc6, 0x97, 0x65, 0x21, 0xc6, 0xad, 0x85, 0xd4, 0xaa,
+	0x85, 0x78, 0x7e, 0x61, 0x49, 0x7f, 0x87, 0x6d, 0xa8, 0x07, 0x22, 0xcd, 0x95, 0x69, 0x97, 0x2b,
+	0xe6, 0x2e, 0x5b, 0xf6, 0x04, 0xea, 0x51, 0x18, 0x5f, 0x4a, 0xd7, 0xa4, 0x6b, 0xa0, 0x8e, 0xed,
+	0x2a, 0x0f, 0xae, 0xb4, 0xde, 0xef, 0xd0, 0x9c, 0x88, 0x7c, 0x18, 0xe7, 0xd9, 0xb2, 0x38, 0x7f,
+	0xba, 0xeb, 0x52, 0xe4, 0xb7, 0x9e, 0xfa, 0xca, 0x7d, 0x36, 0xd7, 0xee, 0xf3, 0xb5, 0xce, 0x58,
+	0x77, 0x74, 0xa6, 0xbe, 0xea, 0x4c, 0x04, 0xf6, 0x44, 0xe4, 0x27, 0x95, 0xd3, 0x5b, 0x89, 0xfd,
+	0x0c, 0x6c, 0x11, 0xe7, 0x59, 0x28, 0xa4, 0x5b, 0xdb, 0x31, 0xcb, 0x3d, 0x2c, 0xb2, 0xe5, 0x85,
+	0xb6, 0xbc, 0xe4, 0x66, 0xe5, 0x92, 0x33, 0xb0, 0x16, 0xf8, 0x29, 0x5b, 0x74, 0x09, 0x88, 0xf6,
+	0x22, 0xe8, 0x1c, 0xfb, 0x61, 0x34, 0x8c, 0x3f, 0x89, 0x28, 0x49, 0x09, 0x73, 0x96, 0x25, 0x8b,
+	0x62, 0x12, 0x48, 0xe3, 0x91, 0xcb, 0x13, 0x5d, 0x6e, 0x2d, 0x4f, 0xca, 0x06, 0x98, 0xeb, 0xff,
+	0x3a, 0x89, 0x57, 0xc6, 0xa2, 0xea, 0x89, 0xbe, 0xa5, 0x36, 0x0e, 0x30, 0x11, 0x7e, 0x24, 0x02,
+	0x8c, 0xc9, 0xbe, 0x82, 0x96, 0x48, 0x2f, 0xc4, 0x42, 0x64, 0x7e, 0xa4, 0x03, 0xae, 0x04, 0x38,
+	0xd9, 0x38, 0x89, 0xe7, 0x42, 0x07, 0x56, 0x0c, 0xfa, 0x3c, 0x4d, 0x3e, 0xeb, 0xd0, 0x48, 0x7a,
+	0x21, 0x00, 0x7a, 0x3b, 0x4d, 0x3e, 0xf7, 0xe7, 0x97, 0x38, 0x88, 0x85, 0xe2, 0xb4, 0xc7, 0x82,
+	0x45, 0xcb, 0x30, 0x50, 0x6d, 0xeb, 0x70, 0x24, 0xaf, 0x8d, 0xc6, 0xbc, 0x63, 0x34, 0xab, 0x5f,
+	0xb5, 0xf7, 0xb7, 0x01, 0x30, 0xf2, 0x17, 0x42, 0xfd, 0x9e, 0xb1, 0xe6, 0xd8, 0x5f, 0x08, 0x0a,
+	0xd4, 0xe2, 0x44, 0x57, 0x5f, 0x22, 0xb5, 0xdb, 0x5f, 0x22, 0x66, 0xf5, 0x25, 0x52, 0xd9, 0x1b,
+	0x6b, 0x7d, 0x6f, 0x68, 0xf6, 0x1f, 0xa9, 0x79, 0x16, 0xce, 0xfe, 0xe3, 0xb5, 0x74, 0x1b, 0x77,
+	0xa4, 0x6b, 0x97, 0xe9, 0xee, 0x7e, 0x80, 0x76, 0xe5, 0xdf, 0xc3, 0x9a, 0x60, 0x8d, 0xc6, 0xe3,
+	0x13, 0x67, 0x03, 0xa9, 0x93, 0xc3, 0xd1, 0x1b, 0xc7, 0x60, 0x2d, 0xa8, 0x4f, 0xa6, 0x63, 0x3e,
+	0x74, 0x6a, 0xac, 0x0b, 0xad, 0xd7, 0x87, 0xa3, 0x57, 0xb3, 0xd1, 0xf8, 0xd5, 0xd0, 0x31, 0xd9,
+	0x26, 0x00, 0xb1, 0xbf, 0xf6, 0x8f, 0x7e, 0x19, 0x3a, 0x16, 0xf2, 0x84, 0x9c, 0x1d, 0xf7, 0x47,
+	0xef, 0x9d, 0x7a, 0x09, 0x27, 0xb6, 0xb1, 0xfb, 0x0e, 0x36, 0xd7, 0xdf, 0x4e, 0xec, 0x1e, 0x74,
+	0x47, 0xe3, 0xe9, 0x6c, 0x30, 0x1e, 0x8d, 0x86, 0x83, 0xe9, 0xf0, 0x95, 0xb3, 0x81, 0x36, 0x2b,
+	0xd6, 0x60, 0x5b, 0xd0, 0xd6, 0x6c, 0x7f, 0xff, 0x08, 0x53, 0x60, 0xb0, 0x39, 0xe8, 0x8f, 0x2a,
+	0x56, 0x8e, 0xb9, 0xfb, 0x07, 0xc0, 0xea, 0x35, 0xc4, 0x6c, 0x30, 0x79, 0xff, 0x9d, 0xb3, 0x81,
+	0xd0, 0xc9, 0x41, 0x9f, 0x53, 0xfc, 0xc3, 0xd7, 0xc3, 0xc9, 0x54, 0xf9, 0x1b, 0xef, 0xff, 0x3c,
+	0x1c, 0x4c, 0x55, 0x0d, 0x54, 0xd2, 0x64, 0x38, 0x9d, 0x0d, 0x47, 0x53, 0xfe, 0xde, 0x31, 0x59,
+	0x07, 0x9a, 0xc8, 0x9e, 0xf4, 0xdf, 0x60, 0x41, 0x5b, 0xd0, 0x3e, 0xee, 0x1f, 0x1e, 0xed, 0x8f,
+	0x7f, 0x9b, 0xf5, 0x07, 0x6f, 0x9d, 0x3a, 0xf5, 0xa7, 0x7f, 0x3c, 0x74, 0x1a, 0xd4, 0x15, 0x74,
+	0xee, 0xd8, 0xa7, 0x0d, 0x7a, 0x92, 0xbe, 0xf8, 0x6f, 0x00, 0x89, 0x59, 0x03, 0x0e, 0xa1, 0x0a,
+	0x00, 0x00,
 }
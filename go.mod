@@ -3,10 +3,14 @@ module github.com/zigmahq/zigma
 go 1.13
 
 require (
+	github.com/dgraph-io/badger v1.6.0-rc1
 	github.com/go-stack/stack v1.8.0
 	github.com/go-yaml/yaml v2.1.0+incompatible
+	github.com/gogo/protobuf v1.3.0
 	github.com/golang/protobuf v1.3.1
 	github.com/google/uuid v1.1.1
+	github.com/klauspost/cpuid v1.3.1 // indirect
+	github.com/klauspost/reedsolomon v1.9.3
 	github.com/libp2p/go-libp2p v0.4.0
 	github.com/libp2p/go-libp2p-autonat-svc v0.1.0
 	github.com/libp2p/go-libp2p-circuit v0.1.3
@@ -22,8 +26,10 @@ require (
 	github.com/libp2p/go-libp2p-secio v0.2.0
 	github.com/multiformats/go-multiaddr v0.1.1
 	github.com/multiformats/go-multicodec v0.1.6
+	github.com/multiformats/go-multihash v0.0.8
 	github.com/spf13/cobra v0.0.5
 	github.com/stretchr/testify v1.3.0
+	golang.org/x/crypto v0.0.0-20190923035154-9ee001bba392
 	golang.org/x/time v0.0.0-20190921001708-c4c64cad1fd0
 	golang.org/x/tools v0.0.0-20190311212946-11955173bddd
 )
@@ -2,36 +2,307 @@ package main
 
 import (
 	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
 
+	"github.com/spf13/cobra"
+	"github.com/zigmahq/zigma/admin"
 	"github.com/zigmahq/zigma/config"
+	"github.com/zigmahq/zigma/dht"
 	"github.com/zigmahq/zigma/log"
 	"github.com/zigmahq/zigma/node"
+	"github.com/zigmahq/zigma/p2p"
+	"github.com/zigmahq/zigma/store"
 )
 
-func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	logger := log.NewLogger()
-	logger.Info("Zigma Node",
-		log.String("version", "1.0.0-3687"),
-		log.String("by", "Nakama Hiroyasu"),
-	)
-	logger.Info("Chain",
-		log.String("specification", "Local Testnet"),
-		log.String("node-name", "pretty-dress-0716"),
-	)
-	cfg := config.DefaultConfig()
-
-	node, err := node.NewNode(ctx, cfg)
+var logger = log.NewLogger()
+
+var cmd = &cobra.Command{
+	Use:   "zigma",
+	Short: "Zigma node",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		logger.Info("Zigma Node",
+			log.String("version", "1.0.0-3687"),
+			log.String("by", "Nakama Hiroyasu"),
+		)
+		logger.Info("Chain",
+			log.String("specification", "Local Testnet"),
+			log.String("node-name", "pretty-dress-0716"),
+		)
+		cfg, err := readConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		node, err := node.NewNode(ctx, cfg)
+		if err != nil {
+			return err
+		}
+
+		if err := node.Start(); err != nil {
+			return err
+		}
+		return node.Stop()
+	},
+}
+
+var crawl = &cobra.Command{
+	Use:   "crawl",
+	Short: "Crawl the dht from the seed nodes and write the nodes found as json",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cfg, err := readConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		srv, err := p2p.NewServer(ctx, cfg.P2P, nil)
+		if err != nil {
+			return err
+		}
+		defer srv.Stop()
+
+		dir, err := ioutil.TempDir("", "zigma-crawl")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+
+		db, err := store.NewBadgerStore(dir)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		kad := dht.NewKademliaClient(srv.KademliaNode(), db, srv.KademliaRPC())
+		defer kad.Stop()
+
+		seeds := srv.KademliaSeeds()
+		logger.Info("Crawling the dht", log.Int("seeds", len(seeds)))
+		res := kad.Crawl(seeds...)
+		logger.Info("Crawl finished",
+			log.Int("nodes", len(res.Nodes)),
+			log.Int("reachable", res.Reachable),
+			log.String("elapsed", res.Elapsed.String()),
+		)
+
+		out, _ := cmd.Flags().GetString("out")
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return res.WriteJSON(f)
+	},
+}
+
+var db = &cobra.Command{
+	Use:   "db",
+	Short: "Manage the node database",
+}
+
+var dbBackup = &cobra.Command{
+	Use:   "backup",
+	Short: "Write a full or incremental backup of the node database",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, _ := cmd.Flags().GetString("out")
+		since, _ := cmd.Flags().GetUint64("since")
+
+		s, release, err := openBackupStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		next, err := s.Backup(f, since)
+		if err != nil {
+			return err
+		}
+		logger.Info("Backup written",
+			log.String("out", out),
+			log.Uint64("since", since),
+			log.Uint64("next", next),
+		)
+		return f.Sync()
+	},
+}
+
+var dbRestore = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore the node database from full and incremental backups, in order",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, release, err := openBackupStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		for _, in := range args {
+			f, err := os.Open(in)
+			if err != nil {
+				return err
+			}
+			err = s.Restore(f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			logger.Info("Backup restored", log.String("in", in))
+		}
+		return nil
+	},
+}
+
+var dbStats = &cobra.Command{
+	Use:   "stats",
+	Short: "Write the key counts and bytes of every namespace of the node database as json",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		window, _ := cmd.Flags().GetDuration("window")
+		if c, err := dialNode(cmd); err == nil {
+			defer c.Close()
+			stats, err := c.Stats(window)
+			if err != nil {
+				return err
+			}
+			return stats.WriteJSON(os.Stdout)
+		}
+
+		s, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		stats := store.ReadStats(s, window)
+		return stats.WriteJSON(os.Stdout)
+	},
+}
+
+var dbRotateKey = &cobra.Command{
+	Use:   "rotate-key",
+	Short: "Re-encrypt the node database with the current encryption key, the node must be stopped",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if c, err := dialNode(cmd); err == nil {
+			c.Close()
+			return errors.New("stop the node before rotating the encryption key")
+		}
+		s, err := openStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		es, ok := s.(*store.EncryptedStore)
+		if !ok {
+			return errors.New("encryption is not enabled")
+		}
+		n, err := es.Rotate()
+		if err != nil {
+			return err
+		}
+		logger.Info("Encryption key rotated", log.Int("values", n))
+		return nil
+	},
+}
+
+// backupStore is the part of the store used by the backup commands, served by
+// a running node or by the database opened directly
+type backupStore interface {
+	Backup(w io.Writer, since uint64) (uint64, error)
+	Restore(r io.Reader) error
+}
+
+// openBackupStore returns a client of the node running on the database, or
+// opens the database if no node is running; release closes the database
+func openBackupStore(cmd *cobra.Command) (backupStore, func(), error) {
+	if c, err := dialNode(cmd); err == nil {
+		logger.Info("Using the database of the running node")
+		return c, c.Close, nil
+	}
+	db, err := openStore(cmd)
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
+	return db, db.Close, nil
+}
 
-	if err := node.Start(); err != nil {
-		panic(err)
+// dialNode connects to the admin socket of the node running on the database,
+// returns admin.ErrNotRunning if no node is running
+func dialNode(cmd *cobra.Command) (*admin.Client, error) {
+	cfg, err := storageConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return admin.Dial(cfg.AdminSocket())
+}
+
+// openStore opens the node database and upgrades its storage format, the
+// database is locked by a running node and cannot be opened while it runs
+func openStore(cmd *cobra.Command) (store.Store, error) {
+	cfg, err := storageConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	s, err := cfg.Open()
+	if err != nil {
+		return nil, err
 	}
-	if err := node.Stop(); err != nil {
-		panic(err)
+	if err := dht.MigrateStore(s); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// storageConfig returns the storage configuration given by the config flag, or
+// the database directory given by the path flag
+func storageConfig(cmd *cobra.Command) (*config.Storage, error) {
+	cfg, err := readConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if path, _ := cmd.Flags().GetString("path"); len(path) > 0 {
+		cfg.Storage.Path = path
+	}
+	return cfg.Storage, nil
+}
+
+// readConfig reads the configuration file given by the config flag, or returns
+// the default configuration
+func readConfig(cmd *cobra.Command) (*config.Config, error) {
+	if path, _ := cmd.Flags().GetString("config"); len(path) > 0 {
+		return config.FromFile(path)
+	}
+	return config.DefaultConfig(), nil
+}
+
+func main() {
+	cmd.PersistentFlags().String("config", "", "path to the yaml configuration file")
+	crawl.Flags().StringP("out", "o", "crawl.json", "path of the json crawl result")
+	db.PersistentFlags().String("path", "", "path of the database directory, overrides the configuration")
+	dbBackup.Flags().StringP("out", "o", "zigma.bak", "path of the backup file")
+	dbBackup.Flags().Uint64("since", 0, "version returned by the previous backup, 0 for a full backup")
+
+	dbStats.Flags().Duration("window", time.Hour, "keys expiring within the window are counted as expiring soon")
+
+	db.AddCommand(dbBackup, dbRestore, dbStats, dbRotateKey)
+	cmd.AddCommand(crawl, db)
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
 	}
 }
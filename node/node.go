@@ -4,11 +4,16 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 
+	"github.com/zigmahq/zigma/admin"
 	"github.com/zigmahq/zigma/config"
+	"github.com/zigmahq/zigma/dht"
 	"github.com/zigmahq/zigma/log"
 	"github.com/zigmahq/zigma/p2p"
+	"github.com/zigmahq/zigma/store"
 )
 
 var logger = log.DefaultLogger
@@ -16,31 +21,74 @@ var logger = log.DefaultLogger
 // Node encapsulates a znode server
 type Node struct {
 	*p2p.P2P
-	ctx  context.Context
-	stop chan os.Signal
+	Store  store.Store
+	DHT    *dht.Kademlia
+	ctx    context.Context
+	stop   chan os.Signal
+	socket string
+	admin  *admin.Server
 }
 
-// Start starts the node and p2p services
+// Start starts the node and p2p services, the dht is bootstrapped from the
+// seed nodes in the background, and the mailbox of the node is read once the
+// dht is bootstrapped. The database commands are served on the admin
+// socket while the node runs
 func (n *Node) Start() error {
+	if err := os.MkdirAll(filepath.Dir(n.socket), 0700); err != nil {
+		return err
+	}
+	srv, err := admin.Listen(n.socket, n.Store, n.DHT)
+	if err != nil {
+		return err
+	}
+	n.admin = srv
+
 	if err := n.P2P.Start(); err != nil {
+		srv.Close()
 		return err
 	}
+	go func() {
+		res := n.DHT.Bootstrap(n.KademliaSeeds()...)
+		logger.Info("DHT bootstrapped",
+			log.Int("contacts", res.Size),
+			log.String("elapsed", res.Elapsed.String()),
+		)
+		read, err := n.ReadMail()
+		if err != nil {
+			logger.Error("unable to read mailbox: " + err.Error())
+			return
+		}
+		logger.Info("Mailbox read", log.Int("messages", read))
+	}()
 	signal.Notify(n.stop, syscall.SIGINT)
 	<-n.stop
 	logger.NL()
 	return nil
 }
 
-// Stop to stop all the active services
+// Stop to stop all the active services, the store is closed last
 func (n *Node) Stop() error {
+	defer n.Store.Close()
+
+	if n.admin != nil {
+		n.admin.Close()
+	}
+	n.DHT.Stop()
 	return n.P2P.Stop()
 }
 
+// StorageStats reports the usage of the node database by namespace, keys
+// expiring within the window are counted as expiring soon
+func (n *Node) StorageStats(window time.Duration) store.Stats {
+	return store.ReadStats(n.Store, window)
+}
+
 // NewNode initializes and returns a zigma node
 func NewNode(ctx context.Context, conf *config.Config) (*Node, error) {
 	n := &Node{
-		ctx:  ctx,
-		stop: make(chan os.Signal, 1),
+		ctx:    ctx,
+		stop:   make(chan os.Signal, 1),
+		socket: conf.Storage.AdminSocket(),
 	}
 
 	p, err := p2p.NewServer(ctx, conf.P2P, n)
@@ -49,5 +97,35 @@ func NewNode(ctx context.Context, conf *config.Config) (*Node, error) {
 	}
 	n.P2P = p
 
+	db, err := conf.Storage.Open()
+	if err != nil {
+		p.Stop()
+		return nil, err
+	}
+	if err := dht.MigrateStore(db); err != nil {
+		db.Close()
+		p.Stop()
+		return nil, err
+	}
+	n.Store = db
+
+	// the dht signs its records with the node key, and resolves the name
+	// addresses of the p2p server
+	n.DHT = dht.NewKademlia(p.KademliaNode(), db, p.KademliaRPC())
+	if key, err := conf.P2P.DecodePrivateKey(); err == nil {
+		n.DHT.SetPrivateKey(key)
+	}
+	p.SetNameResolver(n.DHT)
+	p.SetMailbox(n.DHT)
+
+	if sc := conf.Storage.Scrub; sc != nil && sc.Enable {
+		n.DHT.StartScrubber(dht.ScrubOptions{
+			Rate:       sc.Rate,
+			Interval:   sc.Interval,
+			Quarantine: sc.Quarantine,
+			Logger:     logger,
+		})
+	}
+
 	return n, nil
 }
@@ -3,6 +3,7 @@ package node
 import (
 	"fmt"
 
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/zigmahq/zigma/p2p"
 )
 
@@ -15,6 +16,13 @@ func (n *Node) MessageReceived(ws *p2p.WrappedStream, m p2p.Message) error {
 	return nil
 }
 
+// MailReceived implements the handler for P2P.Implementer.MailReceived, the
+// message was left in the mailbox of the node while it was offline
+func (n *Node) MailReceived(pid peer.ID, m p2p.Message) error {
+	fmt.Println("mail received", pid.Pretty(), m)
+	return nil
+}
+
 // MessageSent implements the handler for P2P.Implementer.MessageSent
 func (n *Node) MessageSent(ws *p2p.WrappedStream, m p2p.Message) error {
 	fmt.Println("message sent", m)
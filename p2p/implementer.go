@@ -15,4 +15,5 @@ type Implementer interface {
 	PeerStreamClosed(network.Network, network.Stream)
 	MessageReceived(*WrappedStream, Message) error
 	MessageSent(*WrappedStream, Message) error
+	MailReceived(peer.ID, Message) error
 }
@@ -0,0 +1,250 @@
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/multiformats/go-multiaddr"
+	multicodec "github.com/multiformats/go-multicodec"
+	protobuf "github.com/multiformats/go-multicodec/protobuf"
+	"github.com/zigmahq/zigma/dht"
+)
+
+// the default time to wait for a kademlia reply, and for a local reply to an
+// incoming kademlia request
+const kademliaTimeout = time.Second / 2
+
+// kademliaStream wraps a libp2p stream carrying kademlia messages
+type kademliaStream struct {
+	stream network.Stream
+	enc    multicodec.Encoder
+	dec    multicodec.Decoder
+	w      *bufio.Writer
+}
+
+func wrapKademliaStream(s network.Stream) *kademliaStream {
+	w := bufio.NewWriter(s)
+	return &kademliaStream{
+		stream: s,
+		enc:    protobuf.Multicodec(new(dht.Message)).Encoder(w),
+		dec:    protobuf.Multicodec(new(dht.Message)).Decoder(bufio.NewReader(s)),
+		w:      w,
+	}
+}
+
+func (ks *kademliaStream) send(msg *dht.Message) error {
+	if err := ks.enc.Encode(msg); err != nil {
+		return err
+	}
+	return ks.w.Flush()
+}
+
+func (ks *kademliaStream) receive() (*dht.Message, error) {
+	msg := new(dht.Message)
+	if err := ks.dec.Decode(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// kademliaRPC implements dht.KademliaRPC over libp2p streams, a request and its
+// reply share a single stream. Pending requests are keyed by the peer they came
+// from and their id, so that peers cannot answer each other's requests
+type kademliaRPC struct {
+	ctx      context.Context
+	host     host.Host
+	protocol protocol.ID
+	receive  chan *dht.Message
+	pending  *sync.Map
+}
+
+// Write sends a kademlia message to its receiver. Replies are written to the
+// stream the request came in on
+func (r *kademliaRPC) Write(msg *dht.Message) dht.KademliaReplyFn {
+	if msg.IsResponse {
+		if msg.Receiver == nil {
+			return closedReply
+		}
+		pid, err := peer.IDB58Decode(string(msg.Receiver.PeerId))
+		if err != nil {
+			return closedReply
+		}
+		key := pendingKey(pid, msg.Id)
+		if v, ok := r.pending.Load(key); ok {
+			r.pending.Delete(key)
+			ks := v.(*kademliaStream)
+			if err := ks.send(msg); err != nil {
+				logger.Debug("kademlia reply error: " + err.Error())
+			}
+			_ = ks.stream.Close()
+		}
+		return closedReply
+	}
+
+	replies := make(chan *dht.Message, 1)
+	go func() {
+		defer close(replies)
+		ks, err := r.dial(msg.Receiver)
+		if err != nil {
+			logger.Debug("kademlia dial error: " + err.Error())
+			return
+		}
+		defer ks.stream.Close()
+		if err := ks.send(msg); err != nil {
+			logger.Debug("kademlia write error: " + err.Error())
+			return
+		}
+		if len(msg.Id) == 0 {
+			return
+		}
+		_ = ks.stream.SetReadDeadline(time.Now().Add(2 * kademliaTimeout))
+		out, err := ks.receive()
+		if err == nil && string(out.Id) == string(msg.Id) && sentBy(out, ks.stream.Conn().RemotePeer()) {
+			replies <- out
+		}
+	}()
+
+	return func(timeout time.Duration) <-chan *dht.Message {
+		if timeout <= 0 {
+			timeout = kademliaTimeout
+		}
+		wc := make(chan *dht.Message, 1)
+		go func() {
+			select {
+			case out := <-replies:
+				wc <- out
+			case <-time.After(timeout):
+				wc <- nil
+			}
+		}()
+		return wc
+	}
+}
+
+// Read returns the incoming kademlia requests
+func (r *kademliaRPC) Read() <-chan *dht.Message {
+	return r.receive
+}
+
+// dial opens a stream to a dht node, the addresses carried by the node are
+// added to the peerstore beforehand
+func (r *kademliaRPC) dial(node *dht.Node) (*kademliaStream, error) {
+	pid, err := peer.IDB58Decode(string(node.PeerId))
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range node.Addrs {
+		if ma, err := multiaddr.NewMultiaddrBytes(b); err == nil {
+			r.host.Peerstore().AddAddr(pid, ma, peerstore.TempAddrTTL)
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.ctx, kademliaTimeout)
+	defer cancel()
+	s, err := r.host.NewStream(ctx, pid, r.protocol)
+	if err != nil {
+		return nil, err
+	}
+	return wrapKademliaStream(s), nil
+}
+
+// handle reads an incoming request, and keeps the stream open until the local
+// node replies or the reply times out. Requests whose sender is not the peer at
+// the other end of the stream are dropped
+func (r *kademliaRPC) handle(s network.Stream) {
+	ks := wrapKademliaStream(s)
+	remote := s.Conn().RemotePeer()
+	msg, err := ks.receive()
+	if err != nil || msg.IsResponse || len(msg.Id) == 0 || !sentBy(msg, remote) {
+		_ = s.Reset()
+		return
+	}
+	key := pendingKey(remote, msg.Id)
+	r.pending.Store(key, ks)
+	time.AfterFunc(2*kademliaTimeout, func() {
+		if _, ok := r.pending.Load(key); ok {
+			r.pending.Delete(key)
+			_ = s.Reset()
+		}
+	})
+
+	select {
+	case r.receive <- msg:
+	case <-r.ctx.Done():
+	}
+}
+
+// sentBy checks that the sender of a message is the dht node of a peer
+func sentBy(msg *dht.Message, pid peer.ID) bool {
+	node := dht.NodeFromPeerID(pid)
+	if node == nil || msg.Sender == nil {
+		return false
+	}
+	return bytes.Equal(msg.Sender.Id, node.Id) &&
+		bytes.Equal(msg.Sender.Hash, node.Hash) &&
+		bytes.Equal(msg.Sender.PeerId, node.PeerId)
+}
+
+// pendingKey identifies a request by the peer it came from and its id
+func pendingKey(pid peer.ID, id []byte) string {
+	return pid.Pretty() + "/" + string(id)
+}
+
+func closedReply(time.Duration) <-chan *dht.Message {
+	wc := make(chan *dht.Message, 1)
+	wc <- nil
+	return wc
+}
+
+// KademliaRPC returns the transport of the zigma kademlia dht
+func (n *P2P) KademliaRPC() dht.KademliaRPC {
+	return n.rpc
+}
+
+// KademliaNode returns the local node as a kademlia dht contact
+func (n *P2P) KademliaNode() *dht.Node {
+	node := dht.NodeFromPeerID(n.host.ID())
+	if node == nil {
+		return nil
+	}
+	for _, addr := range n.host.Addrs() {
+		node.Addrs = append(node.Addrs, addr.Bytes())
+	}
+	return node
+}
+
+// KademliaSeeds returns the seed nodes as kademlia dht contacts, seeds whose
+// peer id does not embed a public key are left out
+func (n *P2P) KademliaSeeds() []*dht.Node {
+	var nodes []*dht.Node
+	for _, pi := range n.cfg.Seeds.PeerInfos() {
+		node := dht.NodeFromPeerID(pi.ID)
+		if node == nil {
+			continue
+		}
+		for _, addr := range pi.Addrs {
+			node.Addrs = append(node.Addrs, addr.Bytes())
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func newKademliaRPC(ctx context.Context, h host.Host, pid protocol.ID) *kademliaRPC {
+	r := &kademliaRPC{
+		ctx:      ctx,
+		host:     h,
+		protocol: pid,
+		receive:  make(chan *dht.Message),
+		pending:  new(sync.Map),
+	}
+	h.SetStreamHandler(pid, r.handle)
+	return r
+}
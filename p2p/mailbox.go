@@ -0,0 +1,61 @@
+package p2p
+
+import (
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/zigmahq/zigma/dht"
+	"github.com/zigmahq/zigma/log"
+)
+
+// the time a message is kept in the mailbox of a peer which can't be reached
+const mailTTL = 24 * time.Hour
+
+// Mailbox keeps messages for peers which are offline
+type Mailbox interface {
+	SendMail(to crypto.PubKey, data []byte, ttl time.Duration) (int, error)
+	ReadMail(fn func(*dht.Mail) error) (int, error)
+}
+
+// SetMailbox attaches the mailbox messages are left in when a peer can't be
+// reached
+func (n *P2P) SetMailbox(mailbox Mailbox) {
+	n.mailbox = mailbox
+}
+
+// mailMessage leaves a message in the mailbox of a peer
+func (n *P2P) mailMessage(msg Message, pid peer.ID) error {
+	pub, err := pid.ExtractPublicKey()
+	if err != nil {
+		return err
+	}
+	b, err := proto.Marshal(&msg)
+	if err != nil {
+		return err
+	}
+	_, err = n.mailbox.SendMail(pub, b, mailTTL)
+	return err
+}
+
+// ReadMail polls the mailbox of the node, and hands the messages left by peers
+// to the implementer; a message is acknowledged, and deleted from the mailbox,
+// once the implementer handled it. Returns the number of messages handled
+func (n *P2P) ReadMail() (int, error) {
+	if n.mailbox == nil || n.itf == nil {
+		return 0, nil
+	}
+	return n.mailbox.ReadMail(func(mail *dht.Mail) error {
+		pid, err := peer.IDFromPublicKey(mail.From)
+		if err != nil {
+			return err
+		}
+		var msg Message
+		if err := proto.Unmarshal(mail.Data, &msg); err != nil {
+			logger.Error("unreadable mail", log.String("peer-id", pid.Pretty()))
+			return nil
+		}
+		return n.itf.MailReceived(pid, msg)
+	})
+}
@@ -46,6 +46,9 @@ type P2P struct {
 	routingDiscovery discovery.Discovery
 	limiter          *rate.Limiter
 	itf              Implementer
+	rpc              *kademliaRPC
+	resolver         NameResolver
+	mailbox          Mailbox
 }
 
 // ID returns the server peer id
@@ -222,6 +225,9 @@ func NewServer(ctx context.Context, p2pconf *config.P2P, itf Implementer) (*P2P,
 	// attach the stream handler
 	host.SetStreamHandler(p2pconf.ProtocolID(), p2p.streamHandlerWrapper)
 
+	// attach the stream handler of the zigma kademlia dht
+	p2p.rpc = newKademliaRPC(ctx, host, p2pconf.ProtocolID()+"/kad")
+
 	// implemenet and attach network notifee interface to receive
 	// notifications from a network.
 	host.Network().Notify(p2p.networkNotifee)
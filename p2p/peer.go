@@ -30,14 +30,31 @@ func (n *P2P) ConnectWithMultiaddr(addrs ...multiaddr.Multiaddr) error {
 	return n.ConnectWithPeerInfo(a...)
 }
 
-// ConnectWithAddr to connect peers with address(es)
+// ConnectWithAddr to connect peers with address(es), name addresses are looked
+// up with the name resolver
 func (n *P2P) ConnectWithAddr(addrs ...*types.Addr) error {
-	in := types.Addrs(addrs)
-	pis := in.PeerInfos()
+	var (
+		in  types.Addrs
+		pis []peerstore.PeerInfo
+	)
+	for _, addr := range addrs {
+		if !addr.IsName() {
+			in = append(in, addr)
+			continue
+		}
+		pi, err := n.ResolveName(addr.Name())
+		if err != nil {
+			return err
+		}
+		pis = append(pis, pi)
+	}
+	pis = append(pis, in.PeerInfos()...)
 	return n.ConnectWithPeerInfo(pis...)
 }
 
-// SendMessage opens a stream and sends a message to the peer target
+// SendMessage opens a stream and sends a message to the peer target, a message
+// which expects no replies is left in the mailbox of a peer which can't be
+// reached
 func (n *P2P) SendMessage(ctx context.Context, msg Message, replies chan Message, pids ...peer.ID) error {
 	for _, pid := range pids {
 		if pid.Pretty() == n.ID() {
@@ -45,7 +62,13 @@ func (n *P2P) SendMessage(ctx context.Context, msg Message, replies chan Message
 		}
 		s, err := n.host.NewStream(ctx, pid, n.cfg.ProtocolID())
 		if err != nil {
-			return err
+			if replies != nil || n.mailbox == nil {
+				return err
+			}
+			if err := n.mailMessage(msg, pid); err != nil {
+				return err
+			}
+			continue
 		}
 		defer s.Close()
 		ws := WrapStream(s)
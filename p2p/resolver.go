@@ -0,0 +1,46 @@
+package p2p
+
+import (
+	"errors"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-peerstore"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/zigmahq/zigma/dht"
+)
+
+// ErrNoResolver is returned when a name address is used without a resolver
+var ErrNoResolver = errors.New("no name resolver")
+
+// NameResolver looks up the record of a registered name
+type NameResolver interface {
+	Resolve(name string) (*dht.NameRecord, error)
+}
+
+// SetNameResolver attaches the resolver used for name addresses
+func (n *P2P) SetNameResolver(resolver NameResolver) {
+	n.resolver = resolver
+}
+
+// ResolveName looks up a registered name, and returns the peer information of
+// the node owning the name
+func (n *P2P) ResolveName(name string) (peerstore.PeerInfo, error) {
+	if n.resolver == nil {
+		return peerstore.PeerInfo{}, ErrNoResolver
+	}
+	record, err := n.resolver.Resolve(name)
+	if err != nil {
+		return peerstore.PeerInfo{}, err
+	}
+	pid, err := peer.IDB58Decode(string(record.PeerId))
+	if err != nil {
+		return peerstore.PeerInfo{}, err
+	}
+	pi := peerstore.PeerInfo{ID: pid}
+	for _, b := range record.Addrs {
+		if ma, err := multiaddr.NewMultiaddrBytes(b); err == nil {
+			pi.Addrs = append(pi.Addrs, ma)
+		}
+	}
+	return pi, nil
+}
@@ -18,25 +18,56 @@
 package store
 
 import (
+	"io"
+	"io/ioutil"
 	"time"
 
 	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/badger/options"
 )
 
+// BadgerOptions encapsulates the options of a badger database
+type BadgerOptions struct {
+	Path               string        // the directory of the database
+	SyncWrites         bool          // sync every write to disk
+	ValueLogFileSize   int64         // the maximum size of a value log file
+	ValueLogMaxEntries uint32        // the maximum number of entries of a value log file
+	InMemory           bool          // load the lsm tree to memory instead of mapping it
+	GCInterval         time.Duration // the interval of value log garbage collection
+	GCRatio            float64       // the ratio of discardable data rewriting a value log file
+}
+
+// DefaultBadgerOptions returns the default options of a badger database
+func DefaultBadgerOptions(path string) BadgerOptions {
+	opt := badger.DefaultOptions(path)
+	return BadgerOptions{
+		Path:               path,
+		SyncWrites:         opt.SyncWrites,
+		ValueLogFileSize:   opt.ValueLogFileSize,
+		ValueLogMaxEntries: opt.ValueLogMaxEntries,
+		GCInterval:         5 * time.Minute,
+		GCRatio:            0.7,
+	}
+}
+
 // BadgerStore implements dgraph-io/badger storage
 type BadgerStore struct {
-	db   *badger.DB
-	quit chan struct{}
+	db    *badger.DB
+	opts  BadgerOptions
+	watch *watchHub
+	quit  chan struct{}
 }
 
-// Init initializes the garbage collection task
+// Init initializes the garbage collection task, the value log is collected
+// until no file is left to rewrite
 func (b *BadgerStore) Init() {
-	ticker := time.NewTicker(5 * time.Minute)
+	ticker := time.NewTicker(b.opts.GCInterval)
 	go func() {
 		for {
 			select {
 			case <-ticker.C:
-				b.db.RunValueLogGC(0.7)
+				for b.db.RunValueLogGC(b.opts.GCRatio) == nil {
+				}
 			case <-b.quit:
 				ticker.Stop()
 				return
@@ -53,64 +84,217 @@ func (b *BadgerStore) Size() int64 {
 
 // Set sets value to a badger key/value storage, passing in negative or 0 as
 // expiration number would not set an expiration time to key
-func (b *BadgerStore) Set(key, val []byte, expiration time.Duration) {
-	txn := b.db.NewTransaction(true)
-	defer txn.Commit()
-
-	entry := badger.NewEntry(key, val)
-	if expiration > 0 {
-		entry.WithTTL(expiration)
-	}
-	_ = txn.SetEntry(entry)
+func (b *BadgerStore) Set(key, val []byte, expiration time.Duration) error {
+	return b.Update(func(txn Txn) error {
+		return txn.Set(key, val, expiration)
+	})
 }
 
 // Get retrieves value from a badger storage
 func (b *BadgerStore) Get(key []byte) ([]byte, bool) {
 	txn := b.db.NewTransaction(false)
+	defer txn.Discard()
+
+	return (&badgerTxn{txn: txn}).Get(key)
+}
+
+// GetVersioned retrieves value from a badger storage along with the version
+// of the key
+func (b *BadgerStore) GetVersioned(key []byte) ([]byte, uint64, bool) {
+	txn := b.db.NewTransaction(false)
+	defer txn.Discard()
+
 	item, err := txn.Get(key)
 	if err != nil {
-		return nil, false
+		return nil, 0, false
 	}
 	data, err := item.ValueCopy(nil)
 	if err != nil {
-		return nil, false
+		return nil, 0, false
 	}
-	return data, true
+	return data, item.Version(), true
+}
+
+// GetWithTTL retrieves value from a badger storage along with the expiration
+// time of the key
+func (b *BadgerStore) GetWithTTL(key []byte) ([]byte, time.Time, bool) {
+	txn := b.db.NewTransaction(false)
+	defer txn.Discard()
+
+	item, err := txn.Get(key)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	data, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	return data, badgerExpiresAt(item), true
+}
+
+// CompareAndSet sets value to a badger storage if the version of the key
+// matches, a concurrent write to the key fails the commit of the transaction
+func (b *BadgerStore) CompareAndSet(key []byte, version uint64, val []byte, expiration time.Duration) error {
+	err := b.Update(func(txn Txn) error {
+		var current uint64
+		item, err := txn.(*badgerTxn).txn.Get(key)
+		if err == nil {
+			current = item.Version()
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+		if current != version {
+			return ErrVersionMismatch
+		}
+		return txn.Set(key, val, expiration)
+	})
+	if err == badger.ErrConflict {
+		return ErrVersionMismatch
+	}
+	return err
 }
 
 // Delete removes an existing key from badger storage
-func (b *BadgerStore) Delete(key []byte) {
-	txn := b.db.NewTransaction(true)
-	defer txn.Commit()
+func (b *BadgerStore) Delete(key []byte) error {
+	return b.Update(func(txn Txn) error {
+		return txn.Delete(key)
+	})
+}
+
+// Update runs the function in a read-write transaction, the writes are
+// committed atomically if the function returns no error
+func (b *BadgerStore) Update(fn func(Txn) error) error {
+	var bt *badgerTxn
+	err := b.db.Update(func(txn *badger.Txn) error {
+		bt = &badgerTxn{txn: txn}
+		return fn(bt)
+	})
+	if err == nil {
+		b.watch.publish(bt.events...)
+	}
+	return err
+}
 
-	_ = txn.Delete(key)
+// Watch delivers the events of the keys with the prefix until cancelled, keys
+// loaded by Restore are not delivered
+func (b *BadgerStore) Watch(prefix []byte) (<-chan Event, func()) {
+	return b.watch.watch(b, prefix)
 }
 
 // Iterate implements the iterate interface for badger storage
 func (b *BadgerStore) Iterate(key []byte) Iterator {
-	txn := b.db.NewTransaction(false)
+	return b.IterateWith(IteratorOptions{Prefix: key})
+}
 
-	opts := badger.IteratorOptions{}
-	opts.PrefetchSize = 10
-	opts.Prefix = key
+// IterateWith implements the iterate interface for badger storage, values are
+// prefetched unless the iteration is keys only
+func (b *BadgerStore) IterateWith(opts IteratorOptions) Iterator {
+	txn := b.db.NewTransaction(false)
 
-	iter := txn.NewIterator(opts)
+	bo := badger.DefaultIteratorOptions
+	bo.PrefetchValues = !opts.KeysOnly
+	bo.Reverse = opts.Reverse
+	bo.PrefetchSize = 10
+	if opts.PrefetchSize > 0 {
+		bo.PrefetchSize = opts.PrefetchSize
+	}
+	// badger ends the iteration at the first key without the prefix, which a
+	// reverse seek to the upper bound may land on
+	if !opts.Reverse {
+		bo.Prefix = opts.Prefix
+	}
 
-	bi := &BadgerIterator{iter: iter}
+	bi := &BadgerIterator{txn: txn, iter: txn.NewIterator(bo), opts: opts}
 	bi.Seek(nil)
 	return bi
 }
 
+// Backup writes the keys at or after the since version to the writer
+func (b *BadgerStore) Backup(w io.Writer, since uint64) (uint64, error) {
+	return b.db.Backup(w, since)
+}
+
+// Restore loads the keys of a backup into the database, badger requires that
+// no other transaction runs while the backup loads
+func (b *BadgerStore) Restore(r io.Reader) error {
+	return b.db.Load(r, 256)
+}
+
+// badgerTxn implements the transaction interface for badger storage, the
+// writes are recorded as events delivered once the transaction commits
+type badgerTxn struct {
+	txn    *badger.Txn
+	events []Event
+}
+
+func (t *badgerTxn) Set(key, val []byte, expiration time.Duration) error {
+	entry := badger.NewEntry(key, val)
+	ev := Event{Type: EventSet, Key: append([]byte{}, key...)}
+	if expiration > 0 {
+		entry.WithTTL(expiration)
+		ev.ExpiresAt = time.Now().Add(expiration)
+	}
+	if err := t.txn.SetEntry(entry); err != nil {
+		return err
+	}
+	t.events = append(t.events, ev)
+	return nil
+}
+
+func (t *badgerTxn) Get(key []byte) ([]byte, bool) {
+	item, err := t.txn.Get(key)
+	if err != nil {
+		return nil, false
+	}
+	data, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (t *badgerTxn) Delete(key []byte) error {
+	if err := t.txn.Delete(key); err != nil {
+		return err
+	}
+	t.events = append(t.events, Event{Type: EventDelete, Key: append([]byte{}, key...)})
+	return nil
+}
+
 // Close closes a database
 func (b *BadgerStore) Close() {
+	b.watch.close()
 	close(b.quit)
 	b.db.Close()
 }
 
 // NewBadgerStore opens a badger database from the provided path
 func NewBadgerStore(path string) (Store, error) {
-	opt := badger.DefaultOptions(path)
+	return NewBadgerStoreWithOptions(DefaultBadgerOptions(path))
+}
+
+// NewBadgerStoreWithOptions opens a badger database with the options
+func NewBadgerStoreWithOptions(opts BadgerOptions) (Store, error) {
+	def := DefaultBadgerOptions(opts.Path)
+	if opts.GCInterval <= 0 {
+		opts.GCInterval = def.GCInterval
+	}
+	if opts.GCRatio <= 0 || opts.GCRatio >= 1 {
+		opts.GCRatio = def.GCRatio
+	}
+
+	opt := badger.DefaultOptions(opts.Path)
 	opt.Logger = &BadgerLogger{}
+	opt.SyncWrites = opts.SyncWrites
+	if opts.ValueLogFileSize > 0 {
+		opt.ValueLogFileSize = opts.ValueLogFileSize
+	}
+	if opts.ValueLogMaxEntries > 0 {
+		opt.ValueLogMaxEntries = opts.ValueLogMaxEntries
+	}
+	if opts.InMemory {
+		opt.TableLoadingMode = options.LoadToRAM
+	}
 
 	db, err := badger.Open(opt)
 	if err != nil {
@@ -118,7 +302,21 @@ func NewBadgerStore(path string) (Store, error) {
 	}
 	quit := make(chan struct{}, 1)
 
-	store := &BadgerStore{db, quit}
+	store := &BadgerStore{db, opts, newWatchHub(), quit}
 	store.Init()
 	return store, nil
 }
+
+// TempBadgerStore opens a badger database in a new temporary directory, it
+// panics if the database cannot be opened; meant for tests
+func TempBadgerStore() Store {
+	dir, err := ioutil.TempDir("", "zigma-badger")
+	if err != nil {
+		panic(err)
+	}
+	store, err := NewBadgerStore(dir)
+	if err != nil {
+		panic(err)
+	}
+	return store
+}
@@ -18,6 +18,7 @@
 package store
 
 import (
+	"bytes"
 	"time"
 
 	"github.com/dgraph-io/badger"
@@ -25,19 +26,35 @@ import (
 
 // BadgerIterator implements the iterator interface
 type BadgerIterator struct {
+	txn  *badger.Txn
 	iter *badger.Iterator
+	opts IteratorOptions
 	curr int
 }
 
 // BadgerIteratorItem implements the iterator item interface
 type BadgerIteratorItem struct {
-	item *badger.Item
+	item     *badger.Item
+	keysOnly bool
 }
 
-// Seek would seek to the provided key if present, or rewind the
-// iterator cursor all the way to zero-th position if the key is nil
+// Seek would seek to the provided key if present, or the key following it in
+// the order of iteration, and rewinds the iterator cursor to the first key in
+// range if the key is nil
 func (b *BadgerIterator) Seek(key []byte) {
 	b.curr = -1
+	if !b.opts.Reverse {
+		if lower := b.opts.lower(); bytes.Compare(key, lower) < 0 {
+			key = lower
+		}
+		b.iter.Seek(key)
+		return
+	}
+	// a reverse seek lands on the greatest key lesser or equal to the key,
+	// keys at the exclusive upper bound are skipped by Next
+	if upper := b.opts.upper(); key == nil || (upper != nil && bytes.Compare(key, upper) > 0) {
+		key = upper
+	}
 	b.iter.Seek(key)
 }
 
@@ -48,17 +65,27 @@ func (b *BadgerIterator) Next() bool {
 		b.iter.Next()
 	}
 	b.curr++
-	return b.iter.Valid()
+	if b.opts.Limit > 0 && b.curr >= b.opts.Limit {
+		return false
+	}
+	if b.opts.Reverse {
+		upper := b.opts.upper()
+		for b.iter.Valid() && upper != nil && bytes.Compare(b.iter.Item().Key(), upper) >= 0 {
+			b.iter.Next()
+		}
+	}
+	return b.iter.Valid() && b.opts.InRange(b.iter.Item().Key())
 }
 
 // Item returns the current key-value item
 func (b *BadgerIterator) Item() Item {
-	return &BadgerIteratorItem{b.iter.Item()}
+	return &BadgerIteratorItem{b.iter.Item(), b.opts.KeysOnly}
 }
 
-// Done closes the badger iterator instance
+// Done closes the badger iterator instance and discards its transaction
 func (b *BadgerIterator) Done() {
 	b.iter.Close()
+	b.txn.Discard()
 }
 
 // Key returns the badger item key name
@@ -68,6 +95,9 @@ func (b *BadgerIteratorItem) Key() []byte {
 
 // Value returns the badger item key value
 func (b *BadgerIteratorItem) Value() []byte {
+	if b.keysOnly {
+		return nil
+	}
 	t, err := b.item.ValueCopy(nil)
 	if err != nil {
 		return nil
@@ -77,10 +107,14 @@ func (b *BadgerIteratorItem) Value() []byte {
 
 // TTL returns the badger item key expiration time
 func (b *BadgerIteratorItem) TTL() time.Time {
-	ex := b.item.ExpiresAt()
-	if ex > 0 {
-		sec := int64(b.item.ExpiresAt())
-		return time.Unix(sec, 0)
+	return badgerExpiresAt(b.item)
+}
+
+// badgerExpiresAt converts the expiration of a badger item, kept in unix
+// seconds, to a time
+func badgerExpiresAt(item *badger.Item) time.Time {
+	if ex := item.ExpiresAt(); ex > 0 {
+		return time.Unix(int64(ex), 0)
 	}
 	return time.Time{}
 }
@@ -21,9 +21,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strconv"
 	"testing"
-	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/zigmahq/zigma/store"
@@ -49,65 +47,12 @@ func TestNewBadgerStore(t *testing.T) {
 	assert.NotNil(t, db)
 }
 
-func TestBadgerSetEx(t *testing.T) {
-	db, dir := newBadgerDB(t)
-	defer os.RemoveAll(dir)
-	defer db.Close()
-
-	o := []byte{0x62}
-
-	db.Set(o, o, time.Second*2)
-
-	b, ok := db.Get(o)
-	assert.True(t, ok)
-	assert.Equal(t, o, b)
-
-	time.Sleep(time.Second*2 + time.Millisecond)
-
-	b, ok = db.Get(o)
-	assert.False(t, ok)
-	assert.Nil(t, b)
-}
-
-func TestBadgerStoreGetAfterSet(t *testing.T) {
-	db, dir := newBadgerDB(t)
-	defer os.RemoveAll(dir)
-	defer db.Close()
-
-	arr := make([][]byte, 500)
-
-	for i := 0; i < len(arr); i++ {
-		s := strconv.Itoa(i)
-		arr[i] = []byte(s)
-		db.Set(arr[i], arr[i], 0)
-	}
-	for i := 0; i < len(arr); i++ {
-		o, found := db.Get(arr[i])
-		assert.True(t, found)
-		assert.Equal(t, arr[i], o)
-	}
-}
-
-func TestBadgerStoreDeleteAfterSet(t *testing.T) {
-	db, dir := newBadgerDB(t)
-	defer os.RemoveAll(dir)
-	defer db.Close()
-
-	o := []byte{0x61}
-
-	b, ok := db.Get(o)
-	assert.False(t, ok)
-	assert.Nil(t, b)
-
-	db.Set(o, o, 0)
-
-	b, ok = db.Get(o)
-	assert.True(t, ok)
-	assert.Equal(t, o, b)
-
-	db.Delete(o)
-
-	b, ok = db.Get(o)
-	assert.False(t, ok)
-	assert.Nil(t, b)
+func TestBadgerStore(t *testing.T) {
+	testStore(t, func(t *testing.T) (store.Store, func()) {
+		db, dir := newBadgerDB(t)
+		return db, func() {
+			db.Close()
+			os.RemoveAll(dir)
+		}
+	})
 }
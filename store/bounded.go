@@ -0,0 +1,345 @@
+/* Copyright 2019 zigma authors
+ * This file is part of the zigma library.
+ *
+ * The zigma library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The zigma library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+ */
+package store
+
+import (
+	"container/list"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EvictionPolicy decides the keys evicted first when a store is full
+type EvictionPolicy string
+
+// Define eviction policies
+const (
+	EvictLRU           EvictionPolicy = "lru"            // least recently used keys first
+	EvictNearestExpiry EvictionPolicy = "nearest-expiry" // keys expiring the soonest first
+)
+
+// BoundedOptions encapsulates the options of a capacity bounded store
+type BoundedOptions struct {
+	Capacity  int64          // the bytes of keys and values kept at most
+	Policy    EvictionPolicy // the order keys are evicted in
+	Protected []string       // namespaces which are never evicted
+}
+
+// BoundedStore extends a store with a capacity, keys are evicted once the
+// bytes held by keys and values exceed the capacity, until they are back under
+// nine tenths of it. Expired keys are evicted before any other key. Writes are
+// never refused, a full store evicts older keys instead
+type BoundedStore struct {
+	Store
+	opts    BoundedOptions
+	mutex   *sync.Mutex
+	entries map[string]*boundedEntry
+	lru     *list.List
+	usage   int64
+}
+
+type boundedEntry struct {
+	key       string
+	size      int64
+	expiresAt time.Time
+	elem      *list.Element
+}
+
+// Usage returns the bytes held by keys and values
+func (b *BoundedStore) Usage() int64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.usage
+}
+
+// Set stores a value, and evicts keys if the store is over its capacity
+func (b *BoundedStore) Set(key, val []byte, ttl time.Duration) error {
+	return b.Update(func(txn Txn) error {
+		return txn.Set(key, val, ttl)
+	})
+}
+
+// Get retrieves a value, and marks the key as recently used
+func (b *BoundedStore) Get(key []byte) ([]byte, bool) {
+	val, ok := b.Store.Get(key)
+	if ok {
+		b.touch(key)
+	}
+	return val, ok
+}
+
+// touch marks a key as recently used
+func (b *BoundedStore) touch(key []byte) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if e, ok := b.entries[string(key)]; ok {
+		b.lru.MoveToFront(e.elem)
+	}
+}
+
+// GetVersioned retrieves a value along with its version, and marks the key as
+// recently used
+func (b *BoundedStore) GetVersioned(key []byte) ([]byte, uint64, bool) {
+	val, version, ok := b.Store.GetVersioned(key)
+	if ok {
+		b.touch(key)
+	}
+	return val, version, ok
+}
+
+// GetWithTTL retrieves a value along with its expiration time, and marks the
+// key as recently used
+func (b *BoundedStore) GetWithTTL(key []byte) ([]byte, time.Time, bool) {
+	val, expiresAt, ok := b.Store.GetWithTTL(key)
+	if ok {
+		b.touch(key)
+	}
+	return val, expiresAt, ok
+}
+
+// CompareAndSet stores a value if the version of the key matches, and evicts
+// keys if the store is over its capacity
+func (b *BoundedStore) CompareAndSet(key []byte, version uint64, val []byte, ttl time.Duration) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.Store.CompareAndSet(key, version, val, ttl); err != nil {
+		return err
+	}
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	b.add(string(key), int64(len(key)+len(val)), expiresAt)
+	return b.evict()
+}
+
+// Delete removes a key
+func (b *BoundedStore) Delete(key []byte) error {
+	return b.Update(func(txn Txn) error {
+		return txn.Delete(key)
+	})
+}
+
+// Update runs the function in a read-write transaction of the underlying
+// store, and evicts keys if the store is over its capacity once the
+// transaction commits
+func (b *BoundedStore) Update(fn func(Txn) error) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var bt *boundedTxn
+	err := b.Store.Update(func(txn Txn) error {
+		bt = &boundedTxn{txn: txn}
+		return fn(bt)
+	})
+	if err != nil {
+		return err
+	}
+	for _, w := range bt.writes {
+		if w.deleted {
+			b.remove(w.key)
+		} else {
+			b.add(w.key, w.size, w.expiresAt)
+		}
+	}
+	return b.evict()
+}
+
+// Restore loads the keys of a backup, and rebuilds the index of the keys
+func (b *BoundedStore) Restore(r io.Reader) error {
+	if err := b.Store.Restore(r); err != nil {
+		return err
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.index()
+	return b.evict()
+}
+
+// index reads the keys of the underlying store, expects the caller to hold
+// the lock
+func (b *BoundedStore) index() {
+	b.entries = make(map[string]*boundedEntry)
+	b.lru = list.New()
+	b.usage = 0
+
+	iter := b.Store.Iterate(nil)
+	for iter.Next() {
+		item := iter.Item()
+		b.add(string(item.Key()), int64(len(item.Key())+len(item.Value())), item.TTL())
+	}
+	iter.Done()
+}
+
+// add records a key as the most recently used, expects the caller to hold the
+// lock
+func (b *BoundedStore) add(k string, size int64, expiresAt time.Time) {
+	b.remove(k)
+	e := &boundedEntry{key: k, size: size, expiresAt: expiresAt}
+	e.elem = b.lru.PushFront(e)
+	b.entries[k] = e
+	b.usage += size
+}
+
+// remove forgets a key, expects the caller to hold the lock
+func (b *BoundedStore) remove(k string) {
+	if e, ok := b.entries[k]; ok {
+		b.lru.Remove(e.elem)
+		delete(b.entries, k)
+		b.usage -= e.size
+	}
+}
+
+// evict removes keys until the usage is under nine tenths of the capacity,
+// expects the caller to hold the lock
+func (b *BoundedStore) evict() error {
+	if b.usage <= b.opts.Capacity {
+		return nil
+	}
+	target := b.opts.Capacity / 10 * 9
+
+	// expired keys are gone from the underlying store already
+	now := time.Now()
+	for k, e := range b.entries {
+		if !e.expiresAt.IsZero() && !now.Before(e.expiresAt) {
+			b.remove(k)
+		}
+	}
+
+	var victims []string
+	for _, e := range b.candidates() {
+		if b.usage <= target {
+			break
+		}
+		victims = append(victims, e.key)
+		b.remove(e.key)
+	}
+
+	for len(victims) > 0 {
+		n := len(victims)
+		if n > dropBatchSize {
+			n = dropBatchSize
+		}
+		err := b.Store.Update(func(txn Txn) error {
+			for _, k := range victims[:n] {
+				if err := txn.Delete([]byte(k)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		victims = victims[n:]
+	}
+	return nil
+}
+
+// candidates returns the keys which are not protected, in eviction order
+func (b *BoundedStore) candidates() []*boundedEntry {
+	var entries []*boundedEntry
+	for elem := b.lru.Back(); elem != nil; elem = elem.Prev() {
+		if e := elem.Value.(*boundedEntry); !b.protected(e.key) {
+			entries = append(entries, e)
+		}
+	}
+	if b.opts.Policy == EvictNearestExpiry {
+		// keys without expiration come last, in least recently used order
+		sort.SliceStable(entries, func(i, j int) bool {
+			a, z := entries[i].expiresAt, entries[j].expiresAt
+			return !a.IsZero() && (z.IsZero() || a.Before(z))
+		})
+	}
+	return entries
+}
+
+// protected checks if a key belongs to a protected namespace, the schema
+// version records are always protected
+func (b *BoundedStore) protected(k string) bool {
+	if strings.HasPrefix(k, schemaNamespace+string(rune(nsSeparator))) {
+		return true
+	}
+	for _, name := range b.opts.Protected {
+		if strings.HasPrefix(k, name+string(rune(nsSeparator))) {
+			return true
+		}
+	}
+	return false
+}
+
+// boundedTxn records the size of the writes of a transaction
+type boundedTxn struct {
+	txn    Txn
+	writes []boundedWrite
+}
+
+type boundedWrite struct {
+	key       string
+	size      int64
+	expiresAt time.Time
+	deleted   bool
+}
+
+func (t *boundedTxn) Set(key, val []byte, ttl time.Duration) error {
+	if err := t.txn.Set(key, val, ttl); err != nil {
+		return err
+	}
+	w := boundedWrite{key: string(key), size: int64(len(key) + len(val))}
+	if ttl > 0 {
+		w.expiresAt = time.Now().Add(ttl)
+	}
+	t.writes = append(t.writes, w)
+	return nil
+}
+
+func (t *boundedTxn) Get(key []byte) ([]byte, bool) {
+	return t.txn.Get(key)
+}
+
+func (t *boundedTxn) Delete(key []byte) error {
+	if err := t.txn.Delete(key); err != nil {
+		return err
+	}
+	t.writes = append(t.writes, boundedWrite{key: string(key), deleted: true})
+	return nil
+}
+
+func (b *BoundedStore) unwrap() Store {
+	return b.Store
+}
+
+// NewBoundedStore wraps a store with a capacity, the keys of the store are
+// read to build the index used for eviction
+func NewBoundedStore(store Store, opts BoundedOptions) *BoundedStore {
+	if opts.Policy == "" {
+		opts.Policy = EvictLRU
+	}
+	b := &BoundedStore{
+		Store: store,
+		opts:  opts,
+		mutex: new(sync.Mutex),
+	}
+	b.index()
+	return b
+}
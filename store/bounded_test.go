@@ -0,0 +1,111 @@
+/* Copyright 2019 zigma authors
+ * This file is part of the zigma library.
+ *
+ * The zigma library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The zigma library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+ */
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/store"
+)
+
+func TestBoundedStore(t *testing.T) {
+	testStore(t, func(t *testing.T) (store.Store, func()) {
+		db := store.NewBoundedStore(store.NewMemoryStore(), store.BoundedOptions{
+			Capacity: 1 << 20,
+		})
+		return db, db.Close
+	})
+}
+
+// every key and value holds 10 bytes
+func boundedKey(c byte) []byte {
+	return []byte{c, c, c, c, c}
+}
+
+func TestBoundedStoreLRU(t *testing.T) {
+	db := store.NewBoundedStore(store.NewMemoryStore(), store.BoundedOptions{
+		Capacity: 50,
+		Policy:   store.EvictLRU,
+	})
+	defer db.Close()
+
+	for c := byte('a'); c <= 'e'; c++ {
+		assert.NoError(t, db.Set(boundedKey(c), boundedKey(c), 0))
+	}
+	assert.Equal(t, int64(50), db.Usage())
+
+	// a is used again, b is the least recently used key
+	_, ok := db.Get(boundedKey('a'))
+	assert.True(t, ok)
+
+	assert.NoError(t, db.Set(boundedKey('f'), boundedKey('f'), 0))
+	assert.Equal(t, int64(40), db.Usage())
+
+	for c, kept := range map[byte]bool{'a': true, 'b': false, 'c': false, 'd': true, 'e': true, 'f': true} {
+		_, ok := db.Get(boundedKey(c))
+		assert.Equal(t, kept, ok, string(c))
+	}
+}
+
+func TestBoundedStoreNearestExpiry(t *testing.T) {
+	db := store.NewBoundedStore(store.NewMemoryStore(), store.BoundedOptions{
+		Capacity: 40,
+		Policy:   store.EvictNearestExpiry,
+	})
+	defer db.Close()
+
+	assert.NoError(t, db.Set(boundedKey('a'), boundedKey('a'), 0))
+	assert.NoError(t, db.Set(boundedKey('b'), boundedKey('b'), time.Hour*2))
+	assert.NoError(t, db.Set(boundedKey('c'), boundedKey('c'), time.Hour))
+	assert.NoError(t, db.Set(boundedKey('d'), boundedKey('d'), 0))
+	assert.NoError(t, db.Set(boundedKey('e'), boundedKey('e'), 0))
+
+	for c, kept := range map[byte]bool{'a': true, 'b': false, 'c': false, 'd': true, 'e': true} {
+		_, ok := db.Get(boundedKey(c))
+		assert.Equal(t, kept, ok, string(c))
+	}
+}
+
+func TestBoundedStoreProtected(t *testing.T) {
+	mem := store.NewMemoryStore()
+	db := store.NewBoundedStore(mem, store.BoundedOptions{
+		Capacity:  40,
+		Protected: []string{"p"},
+	})
+	defer db.Close()
+
+	p := store.Namespace(db, "p")
+	for c := byte('a'); c <= 'c'; c++ {
+		assert.NoError(t, p.Set([]byte{c, c, c}, boundedKey(c), 0))
+	}
+	assert.NoError(t, db.Set(boundedKey('x'), boundedKey('x'), 0))
+	assert.NoError(t, db.Set(boundedKey('y'), boundedKey('y'), 0))
+
+	for c := byte('a'); c <= 'c'; c++ {
+		_, ok := p.Get([]byte{c, c, c})
+		assert.True(t, ok)
+	}
+	_, ok := db.Get(boundedKey('x'))
+	assert.False(t, ok)
+	assert.Equal(t, int64(30), db.Usage())
+
+	// the index is rebuilt from the underlying store
+	db = store.NewBoundedStore(mem, store.BoundedOptions{Capacity: 40})
+	assert.Equal(t, int64(30), db.Usage())
+}
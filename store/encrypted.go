@@ -0,0 +1,323 @@
+/* Copyright 2019 zigma authors
+ * This file is part of the zigma library.
+ *
+ * The zigma library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The zigma library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+ */
+package store
+
+import (
+	"bytes"
+	"sync/atomic"
+	"time"
+)
+
+// the number of values re-encrypted in a single transaction by Rotate
+const rotateBatchSize = 256
+
+// the namespace holding the check value of the keyring
+const keyringNamespace = "keyring"
+
+// the check value of the keyring, followed by a byte telling if the store may
+// still hold plaintext values written before encryption was enabled
+var checkValue = []byte("zigma keyring")
+
+// EncryptedStore extends a store with encryption at rest, values are sealed
+// with the current key of a keyring before being written to the underlying
+// store. Keys are stored in plaintext so that the store keeps its ordering.
+// A check value sealed with the keyring is kept in the store, so that a store
+// is never opened with keys other than the keys it was encrypted with
+type EncryptedStore struct {
+	Store
+	keys      *Keyring
+	check     []byte
+	plaintext int32
+}
+
+// Set encrypts and stores a value
+func (e *EncryptedStore) Set(key, val []byte, ttl time.Duration) error {
+	sealed, err := e.keys.seal(key, val)
+	if err != nil {
+		return err
+	}
+	return e.Store.Set(key, sealed, ttl)
+}
+
+// Get retrieves and decrypts a value, values which cannot be decrypted are
+// reported as not found
+func (e *EncryptedStore) Get(key []byte) ([]byte, bool) {
+	sealed, ok := e.Store.Get(key)
+	if !ok {
+		return nil, false
+	}
+	val, err := e.open(key, sealed)
+	if err != nil {
+		return nil, false
+	}
+	return val, true
+}
+
+// GetVersioned retrieves and decrypts a value along with its version
+func (e *EncryptedStore) GetVersioned(key []byte) ([]byte, uint64, bool) {
+	sealed, version, ok := e.Store.GetVersioned(key)
+	if !ok {
+		return nil, 0, false
+	}
+	val, err := e.open(key, sealed)
+	if err != nil {
+		return nil, 0, false
+	}
+	return val, version, true
+}
+
+// GetWithTTL retrieves and decrypts a value along with its expiration time
+func (e *EncryptedStore) GetWithTTL(key []byte) ([]byte, time.Time, bool) {
+	sealed, expiresAt, ok := e.Store.GetWithTTL(key)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	val, err := e.open(key, sealed)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	return val, expiresAt, true
+}
+
+// CompareAndSet encrypts and stores a value if the version of the key matches
+func (e *EncryptedStore) CompareAndSet(key []byte, version uint64, val []byte, ttl time.Duration) error {
+	sealed, err := e.keys.seal(key, val)
+	if err != nil {
+		return err
+	}
+	return e.Store.CompareAndSet(key, version, sealed, ttl)
+}
+
+// Update runs the function in a read-write transaction of the underlying
+// store, values are encrypted and decrypted as they go through the transaction
+func (e *EncryptedStore) Update(fn func(Txn) error) error {
+	return e.Store.Update(func(txn Txn) error {
+		return fn(&encryptedTxn{txn, e})
+	})
+}
+
+// Iterate iterates the decrypted key-value pairs with the prefix
+func (e *EncryptedStore) Iterate(key []byte) Iterator {
+	return e.IterateWith(IteratorOptions{Prefix: key})
+}
+
+// IterateWith iterates the decrypted key-value pairs in range
+func (e *EncryptedStore) IterateWith(opts IteratorOptions) Iterator {
+	return &encryptedIterator{e.Store.IterateWith(opts), e}
+}
+
+// Rotate re-encrypts the values written with a previous key using the current
+// key, and encrypts the plaintext values of a store written before encryption
+// was enabled. Previous keys can be dropped from the keyring once it returns.
+// Returns the number of values re-encrypted
+func (e *EncryptedStore) Rotate() (int, error) {
+	var (
+		n     int
+		batch []Item
+	)
+	flush := func() error {
+		err := e.Store.Update(func(txn Txn) error {
+			for _, item := range batch {
+				sealed, ok := txn.Get(item.Key())
+				if !ok || e.keys.sealedWithCurrent(sealed) {
+					continue
+				}
+				val, err := e.open(item.Key(), sealed)
+				if err != nil {
+					return err
+				}
+				var ttl time.Duration
+				if exp := item.TTL(); !exp.IsZero() {
+					if ttl = time.Until(exp); ttl <= 0 {
+						continue
+					}
+				}
+				if sealed, err = e.keys.seal(item.Key(), val); err != nil {
+					return err
+				}
+				if err := txn.Set(item.Key(), sealed, ttl); err != nil {
+					return err
+				}
+				n++
+			}
+			return nil
+		})
+		batch = batch[:0]
+		return err
+	}
+
+	iter := e.Store.Iterate(nil)
+	defer iter.Done()
+	for iter.Next() {
+		item := iter.Item()
+		if e.keys.sealedWithCurrent(item.Value()) || bytes.Equal(item.Key(), e.check) {
+			continue
+		}
+		batch = append(batch, &rotateItem{
+			key: append([]byte{}, item.Key()...),
+			ttl: item.TTL(),
+		})
+		if len(batch) == rotateBatchSize {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return n, err
+		}
+	}
+	if err := e.writeCheck(false); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// open decrypts a value, values without the header of a key are returned as
+// they are while the store may still hold plaintext values
+func (e *EncryptedStore) open(key, sealed []byte) ([]byte, error) {
+	if atomic.LoadInt32(&e.plaintext) == 1 && !e.keys.sealedWithKnown(sealed) {
+		return sealed, nil
+	}
+	return e.keys.open(key, sealed)
+}
+
+// readCheck verifies the check value of the keyring, a store without a check
+// value has never been encrypted and may hold plaintext values unless it is
+// empty
+func (e *EncryptedStore) readCheck() error {
+	sealed, ok := e.Store.Get(e.check)
+	if !ok {
+		iter := e.Store.IterateWith(IteratorOptions{KeysOnly: true})
+		plaintext := iter.Next()
+		iter.Done()
+		return e.writeCheck(plaintext)
+	}
+	val, err := e.keys.open(e.check, sealed)
+	if err != nil || len(val) != len(checkValue)+1 || !bytes.HasPrefix(val, checkValue) {
+		return ErrWrongKey
+	}
+	atomic.StoreInt32(&e.plaintext, int32(val[len(checkValue)]))
+	return nil
+}
+
+// writeCheck seals the check value of the keyring with the current key
+func (e *EncryptedStore) writeCheck(plaintext bool) error {
+	val := append(append([]byte{}, checkValue...), 0)
+	if plaintext {
+		val[len(checkValue)] = 1
+	}
+	sealed, err := e.keys.seal(e.check, val)
+	if err != nil {
+		return err
+	}
+	if err := e.Store.Set(e.check, sealed, 0); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&e.plaintext, int32(val[len(checkValue)]))
+	return nil
+}
+
+// encryptedTxn encrypts and decrypts the values of a transaction
+type encryptedTxn struct {
+	txn Txn
+	e   *EncryptedStore
+}
+
+func (t *encryptedTxn) Set(key, val []byte, ttl time.Duration) error {
+	sealed, err := t.e.keys.seal(key, val)
+	if err != nil {
+		return err
+	}
+	return t.txn.Set(key, sealed, ttl)
+}
+
+func (t *encryptedTxn) Get(key []byte) ([]byte, bool) {
+	sealed, ok := t.txn.Get(key)
+	if !ok {
+		return nil, false
+	}
+	val, err := t.e.open(key, sealed)
+	if err != nil {
+		return nil, false
+	}
+	return val, true
+}
+
+func (t *encryptedTxn) Delete(key []byte) error {
+	return t.txn.Delete(key)
+}
+
+// encryptedIterator decrypts the values of the items of an iterator
+type encryptedIterator struct {
+	Iterator
+	e *EncryptedStore
+}
+
+func (i *encryptedIterator) Item() Item {
+	return &encryptedItem{i.Iterator.Item(), i.e}
+}
+
+// encryptedItem decrypts the value of an item, the value is nil if it cannot
+// be decrypted
+type encryptedItem struct {
+	Item
+	e *EncryptedStore
+}
+
+func (i *encryptedItem) Value() []byte {
+	sealed := i.Item.Value()
+	if sealed == nil {
+		return nil
+	}
+	val, err := i.e.open(i.Item.Key(), sealed)
+	if err != nil {
+		return nil
+	}
+	return val
+}
+
+// rotateItem keeps the key and expiration of a value pending rotation
+type rotateItem struct {
+	key []byte
+	ttl time.Time
+}
+
+func (r *rotateItem) Key() []byte    { return r.key }
+func (r *rotateItem) Value() []byte  { return nil }
+func (r *rotateItem) TTL() time.Time { return r.ttl }
+
+func (e *EncryptedStore) unwrap() Store {
+	return e.Store
+}
+
+// NewEncryptedStore wraps a store with encryption at rest, returns ErrWrongKey
+// if the store was encrypted with other keys. Values written to the store
+// before encryption was enabled are read as plaintext until Rotate encrypts
+// them
+func NewEncryptedStore(store Store, keys *Keyring) (*EncryptedStore, error) {
+	e := &EncryptedStore{
+		Store: store,
+		keys:  keys,
+		check: Namespace(store, keyringNamespace).key([]byte("check")),
+	}
+	if err := e.readCheck(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,157 @@
+/* Copyright 2019 zigma authors
+ * This file is part of the zigma library.
+ *
+ * The zigma library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The zigma library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+ */
+package store_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/store"
+)
+
+var (
+	testKey     = bytes.Repeat([]byte{0x6b}, 32)
+	testNextKey = bytes.Repeat([]byte{0x6e}, 32)
+)
+
+func TestEncryptedStore(t *testing.T) {
+	keys, err := store.NewKeyring(testKey)
+	assert.NoError(t, err)
+
+	testStore(t, func(t *testing.T) (store.Store, func()) {
+		db, err := store.NewEncryptedStore(store.NewMemoryStore(), keys)
+		assert.NoError(t, err)
+		return db, db.Close
+	})
+}
+
+func TestEncryptedStoreAtRest(t *testing.T) {
+	keys, err := store.NewKeyring(testKey)
+	assert.NoError(t, err)
+
+	mem := store.NewMemoryStore()
+	db, err := store.NewEncryptedStore(mem, keys)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	k, v := []byte("key"), []byte("sensitive value")
+	assert.NoError(t, db.Set(k, v, 0))
+
+	sealed, ok := mem.Get(k)
+	assert.True(t, ok)
+	assert.False(t, bytes.Contains(sealed, v))
+
+	// a value moved to another key fails to decrypt
+	assert.NoError(t, mem.Set([]byte("other"), sealed, 0))
+	_, ok = db.Get([]byte("other"))
+	assert.False(t, ok)
+
+	// the store cannot be opened with another key
+	other, err := store.NewKeyring(testNextKey)
+	assert.NoError(t, err)
+	_, err = store.NewEncryptedStore(mem, other)
+	assert.Equal(t, store.ErrWrongKey, err)
+}
+
+func TestEncryptedStoreRotate(t *testing.T) {
+	keys, err := store.NewKeyring(testKey)
+	assert.NoError(t, err)
+
+	mem := store.NewMemoryStore()
+	defer mem.Close()
+
+	db, err := store.NewEncryptedStore(mem, keys)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Set([]byte("a"), []byte("a"), 0))
+	assert.NoError(t, db.Set([]byte("b"), []byte("b"), time.Hour))
+
+	rotated, err := store.NewKeyring(testNextKey, testKey)
+	assert.NoError(t, err)
+	db, err = store.NewEncryptedStore(mem, rotated)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Set([]byte("c"), []byte("c"), 0))
+
+	n, err := db.Rotate()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	next, err := store.NewKeyring(testNextKey)
+	assert.NoError(t, err)
+	db, err = store.NewEncryptedStore(mem, next)
+	assert.NoError(t, err)
+	for _, k := range [][]byte{[]byte("a"), []byte("b"), []byte("c")} {
+		v, ok := db.Get(k)
+		assert.True(t, ok)
+		assert.Equal(t, k, v)
+	}
+
+	iter := db.Iterate([]byte("b"))
+	assert.True(t, iter.Next())
+	assert.True(t, iter.Item().TTL().After(time.Now()))
+	iter.Done()
+}
+
+func TestEncryptedStorePlaintext(t *testing.T) {
+	mem := store.NewMemoryStore()
+	defer mem.Close()
+
+	// values written before encryption was enabled
+	assert.NoError(t, mem.Set([]byte("a"), []byte("plain a"), 0))
+	assert.NoError(t, mem.Set([]byte("b"), []byte("plain b"), time.Hour))
+
+	keys, err := store.NewKeyring(testKey)
+	assert.NoError(t, err)
+	db, err := store.NewEncryptedStore(mem, keys)
+	assert.NoError(t, err)
+	v, ok := db.Get([]byte("a"))
+	assert.True(t, ok)
+	assert.Equal(t, []byte("plain a"), v)
+
+	n, err := db.Rotate()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	for k, plain := range map[string]string{"a": "plain a", "b": "plain b"} {
+		sealed, ok := mem.Get([]byte(k))
+		assert.True(t, ok)
+		assert.False(t, bytes.Contains(sealed, []byte(plain)))
+
+		v, ok := db.Get([]byte(k))
+		assert.True(t, ok)
+		assert.Equal(t, []byte(plain), v)
+	}
+
+	// once rotated, the store only holds encrypted values
+	assert.NoError(t, mem.Set([]byte("c"), []byte("plain c"), 0))
+	db, err = store.NewEncryptedStore(mem, keys)
+	assert.NoError(t, err)
+	_, ok = db.Get([]byte("c"))
+	assert.False(t, ok)
+}
+
+func TestKeyring(t *testing.T) {
+	_, err := store.NewKeyring([]byte("short"))
+	assert.Equal(t, store.ErrKeySize, err)
+
+	a := store.PassphraseKey("passphrase", []byte("salt"))
+	b := store.PassphraseKey("passphrase", []byte("salt"))
+	assert.Len(t, a, 32)
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, store.PassphraseKey("passphrase", []byte("pepper")))
+}
@@ -0,0 +1,168 @@
+/* Copyright 2019 zigma authors
+ * This file is part of the zigma library.
+ *
+ * The zigma library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The zigma library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+ */
+package store
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// the size of the salt of passphrase derived keys
+const saltSize = 16
+
+// Errors for the encryption keys
+var (
+	ErrKeySize    = errors.New("encryption key must be 32 bytes")
+	ErrKeyUnknown = errors.New("value encrypted with an unknown key")
+	ErrWrongKey   = errors.New("store is encrypted with another key")
+)
+
+// Keyring holds the keys encrypting the values of a store. Values are written
+// with the current key, and read with the key they were written with, so that
+// previous keys can be kept while values are rotated to the current key
+type Keyring struct {
+	current *storeKey
+	keys    map[[8]byte]*storeKey
+}
+
+type storeKey struct {
+	id   [8]byte
+	aead cipher.AEAD
+}
+
+// NewKeyring creates a keyring with the current key and the previous keys
+// being rotated out, every key must be 32 bytes
+func NewKeyring(current []byte, previous ...[]byte) (*Keyring, error) {
+	kr := &Keyring{keys: make(map[[8]byte]*storeKey)}
+	for i, b := range append([][]byte{current}, previous...) {
+		if len(b) != chacha20poly1305.KeySize {
+			return nil, ErrKeySize
+		}
+		aead, err := chacha20poly1305.NewX(b)
+		if err != nil {
+			return nil, err
+		}
+		k := &storeKey{aead: aead}
+		sum := sha256.Sum256(b)
+		copy(k.id[:], sum[:])
+		if i == 0 {
+			kr.current = k
+		}
+		kr.keys[k.id] = k
+	}
+	return kr, nil
+}
+
+// ReadKeyFile reads a key file holding 32 raw bytes, or 64 hex characters
+func ReadKeyFile(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if s := strings.TrimSpace(string(b)); len(s) == hex.EncodedLen(chacha20poly1305.KeySize) {
+		if key, err := hex.DecodeString(s); err == nil {
+			return key, nil
+		}
+	}
+	if len(b) != chacha20poly1305.KeySize {
+		return nil, ErrKeySize
+	}
+	return b, nil
+}
+
+// PassphraseKey derives a key from a passphrase with argon2id
+func PassphraseKey(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, 1, 64*1024, 4, chacha20poly1305.KeySize)
+}
+
+// ReadSaltFile reads the salt of passphrase derived keys, a random salt is
+// written to the file if it does not exist
+func ReadSaltFile(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err == nil {
+		return b, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, salt, 0600); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// seal encrypts a value with the current key, the store key is authenticated
+// along with the value so that values cannot be moved between keys. Sealed
+// values are laid out as <key id><nonce><ciphertext>
+func (kr *Keyring) seal(key, val []byte) ([]byte, error) {
+	k := kr.current
+	out := make([]byte, len(k.id)+k.aead.NonceSize(), len(k.id)+k.aead.NonceSize()+len(val)+k.aead.Overhead())
+	copy(out, k.id[:])
+	nonce := out[len(k.id):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(out, nonce, val, key), nil
+}
+
+// open decrypts a value sealed with any key of the keyring
+func (kr *Keyring) open(key, sealed []byte) ([]byte, error) {
+	var id [8]byte
+	if len(sealed) < len(id) {
+		return nil, ErrKeyUnknown
+	}
+	copy(id[:], sealed)
+	k, ok := kr.keys[id]
+	if !ok {
+		return nil, ErrKeyUnknown
+	}
+	n := len(id) + k.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, ErrKeyUnknown
+	}
+	return k.aead.Open(nil, sealed[len(id):n], sealed[n:], key)
+}
+
+// sealedWithKnown checks if a value starts with the id of a key of the keyring,
+// values without such a header are plaintext
+func (kr *Keyring) sealedWithKnown(sealed []byte) bool {
+	var id [8]byte
+	if len(sealed) < len(id) {
+		return false
+	}
+	copy(id[:], sealed)
+	_, ok := kr.keys[id]
+	return ok
+}
+
+// sealedWithCurrent checks if a sealed value was encrypted with the current key
+func (kr *Keyring) sealedWithCurrent(sealed []byte) bool {
+	return len(sealed) >= len(kr.current.id) && string(sealed[:len(kr.current.id)]) == string(kr.current.id[:])
+}
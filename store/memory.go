@@ -0,0 +1,427 @@
+/* Copyright 2019 zigma authors
+ * This file is part of the zigma library.
+ *
+ * The zigma library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The zigma library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore implements an in-memory storage, keys are kept sorted so that
+// iteration follows the same order as badger
+type MemoryStore struct {
+	mutex   *sync.RWMutex
+	entries map[string]*memoryEntry
+	keys    []string
+	size    int64
+	version uint64
+	watch   *watchHub
+	quit    chan struct{}
+}
+
+type memoryEntry struct {
+	key       []byte
+	val       []byte
+	expiresAt time.Time
+	version   uint64
+}
+
+func newMemoryEntry(key, val []byte, expiration time.Duration) *memoryEntry {
+	e := &memoryEntry{
+		key: append([]byte{}, key...),
+		val: append([]byte{}, val...),
+	}
+	if expiration > 0 {
+		e.expiresAt = time.Now().Add(expiration)
+	}
+	return e
+}
+
+func (e *memoryEntry) event() Event {
+	return Event{Type: EventSet, Key: e.key, ExpiresAt: e.expiresAt}
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// Init initializes the task removing expired keys
+func (m *MemoryStore) Init() {
+	ticker := time.NewTicker(time.Minute)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				m.removeExpired()
+			case <-m.quit:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Size returns the number of bytes held by keys and values
+func (m *MemoryStore) Size() int64 {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.size
+}
+
+// Set sets value to the in-memory storage, passing in negative or 0 as
+// expiration number would not set an expiration time to key
+func (m *MemoryStore) Set(key, val []byte, expiration time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	e := newMemoryEntry(key, val, expiration)
+	m.set(e)
+	m.watch.publish(e.event())
+	return nil
+}
+
+// Get retrieves value from the in-memory storage
+func (m *MemoryStore) Get(key []byte) ([]byte, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.get(string(key))
+}
+
+// GetVersioned retrieves value from the in-memory storage along with the
+// version of the key
+func (m *MemoryStore) GetVersioned(key []byte) ([]byte, uint64, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	e, ok := m.entries[string(key)]
+	if !ok || e.expired(time.Now()) {
+		return nil, 0, false
+	}
+	return append([]byte{}, e.val...), e.version, true
+}
+
+// GetWithTTL retrieves value from the in-memory storage along with the
+// expiration time of the key
+func (m *MemoryStore) GetWithTTL(key []byte) ([]byte, time.Time, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	e, ok := m.entries[string(key)]
+	if !ok || e.expired(time.Now()) {
+		return nil, time.Time{}, false
+	}
+	return append([]byte{}, e.val...), e.expiresAt, true
+}
+
+// CompareAndSet sets value to the in-memory storage if the version of the key
+// matches
+func (m *MemoryStore) CompareAndSet(key []byte, version uint64, val []byte, expiration time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var current uint64
+	if e, ok := m.entries[string(key)]; ok && !e.expired(time.Now()) {
+		current = e.version
+	}
+	if current != version {
+		return ErrVersionMismatch
+	}
+	e := newMemoryEntry(key, val, expiration)
+	m.set(e)
+	m.watch.publish(e.event())
+	return nil
+}
+
+// Delete removes an existing key from the in-memory storage
+func (m *MemoryStore) Delete(key []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.delete(string(key))
+	m.watch.publish(Event{Type: EventDelete, Key: append([]byte{}, key...)})
+	return nil
+}
+
+// Update runs the function in a read-write transaction holding the write
+// lock, the writes are staged and only applied if the function returns no
+// error
+func (m *MemoryStore) Update(fn func(Txn) error) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	txn := &memoryTxn{store: m, writes: make(map[string]*memoryEntry)}
+	if err := fn(txn); err != nil {
+		return err
+	}
+	events := make([]Event, len(txn.order))
+	for i, k := range txn.order {
+		if e := txn.writes[k]; e != nil {
+			m.set(e)
+			events[i] = e.event()
+		} else {
+			m.delete(k)
+			events[i] = Event{Type: EventDelete, Key: []byte(k)}
+		}
+	}
+	m.watch.publish(events...)
+	return nil
+}
+
+// Watch delivers the events of the keys with the prefix until cancelled
+func (m *MemoryStore) Watch(prefix []byte) (<-chan Event, func()) {
+	return m.watch.watch(m, prefix)
+}
+
+// get expects the caller to hold the lock
+func (m *MemoryStore) get(k string) ([]byte, bool) {
+	e, ok := m.entries[k]
+	if !ok || e.expired(time.Now()) {
+		return nil, false
+	}
+	return append([]byte{}, e.val...), true
+}
+
+// set expects the caller to hold the lock
+func (m *MemoryStore) set(e *memoryEntry) {
+	m.version++
+	e.version = m.version
+
+	k := string(e.key)
+	if old, ok := m.entries[k]; ok {
+		m.size -= int64(len(old.key) + len(old.val))
+	} else {
+		i := sort.SearchStrings(m.keys, k)
+		m.keys = append(m.keys, "")
+		copy(m.keys[i+1:], m.keys[i:])
+		m.keys[i] = k
+	}
+	m.entries[k] = e
+	m.size += int64(len(e.key) + len(e.val))
+}
+
+// delete expects the caller to hold the lock
+func (m *MemoryStore) delete(k string) {
+	e, ok := m.entries[k]
+	if !ok {
+		return
+	}
+	delete(m.entries, k)
+	m.size -= int64(len(e.key) + len(e.val))
+
+	i := sort.SearchStrings(m.keys, k)
+	if i < len(m.keys) && m.keys[i] == k {
+		m.keys = append(m.keys[:i], m.keys[i+1:]...)
+	}
+}
+
+// Iterate implements the iterate interface for the in-memory storage, the
+// iterator reads a snapshot of the keys with the prefix
+func (m *MemoryStore) Iterate(key []byte) Iterator {
+	return m.IterateWith(IteratorOptions{Prefix: key})
+}
+
+// IterateWith implements the iterate interface for the in-memory storage, the
+// iterator reads a snapshot of the keys in range
+func (m *MemoryStore) IterateWith(opts IteratorOptions) Iterator {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var (
+		now   = time.Now()
+		items []*memoryEntry
+	)
+	for i := sort.SearchStrings(m.keys, string(opts.lower())); i < len(m.keys); i++ {
+		e := m.entries[m.keys[i]]
+		if !opts.InRange(e.key) {
+			break
+		}
+		if !e.expired(now) {
+			items = append(items, e)
+		}
+	}
+
+	mi := &MemoryIterator{opts: opts, items: items}
+	mi.Seek(nil)
+	return mi
+}
+
+// Backup writes the keys written at or after the since version to the writer,
+// every key is encoded as its version, key, value and expiration. Deleted keys
+// are not tracked, an incremental backup does not carry deletions
+func (m *MemoryStore) Backup(w io.Writer, since uint64) (uint64, error) {
+	m.mutex.RLock()
+	var (
+		now     = time.Now()
+		version = m.version
+		items   []*memoryEntry
+	)
+	for _, k := range m.keys {
+		if e := m.entries[k]; e.version >= since && !e.expired(now) {
+			items = append(items, e)
+		}
+	}
+	m.mutex.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, e := range items {
+		var expiresAt int64
+		if !e.expiresAt.IsZero() {
+			expiresAt = e.expiresAt.UnixNano()
+		}
+		writeMemoryUvarint(bw, e.version)
+		writeMemoryUvarint(bw, uint64(len(e.key)))
+		bw.Write(e.key)
+		writeMemoryUvarint(bw, uint64(len(e.val)))
+		bw.Write(e.val)
+		buf := make([]byte, binary.MaxVarintLen64)
+		bw.Write(buf[:binary.PutVarint(buf, expiresAt)])
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	return version + 1, nil
+}
+
+// writeMemoryUvarint writes a varint of a backup, write errors surface when
+// the buffered writer is flushed
+func writeMemoryUvarint(w *bufio.Writer, x uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	w.Write(buf[:binary.PutUvarint(buf, x)])
+}
+
+// Restore loads the keys of a backup, keys which have expired since the
+// backup are left out
+func (m *MemoryStore) Restore(r io.Reader) error {
+	br := bufio.NewReader(r)
+	now := time.Now()
+	for {
+		if _, err := binary.ReadUvarint(br); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		key, err := readMemoryBytes(br)
+		if err != nil {
+			return err
+		}
+		val, err := readMemoryBytes(br)
+		if err != nil {
+			return err
+		}
+		expiresAt, err := binary.ReadVarint(br)
+		if err != nil {
+			return err
+		}
+
+		e := newMemoryEntry(key, val, 0)
+		if expiresAt > 0 {
+			e.expiresAt = time.Unix(0, expiresAt)
+		}
+		if e.expired(now) {
+			continue
+		}
+		m.mutex.Lock()
+		m.set(e)
+		m.mutex.Unlock()
+	}
+}
+
+// readMemoryBytes reads a length prefixed byte slice of a backup
+func readMemoryBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Close stops the task removing expired keys
+func (m *MemoryStore) Close() {
+	m.watch.close()
+	close(m.quit)
+}
+
+func (m *MemoryStore) removeExpired() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	for k, e := range m.entries {
+		if e.expired(now) {
+			m.delete(k)
+		}
+	}
+}
+
+// memoryTxn stages the writes of a transaction, a nil entry marks a deleted
+// key. Reads see the staged writes before the stored entries
+type memoryTxn struct {
+	store  *MemoryStore
+	writes map[string]*memoryEntry
+	order  []string
+}
+
+func (t *memoryTxn) Set(key, val []byte, expiration time.Duration) error {
+	t.stage(string(key), newMemoryEntry(key, val, expiration))
+	return nil
+}
+
+func (t *memoryTxn) Get(key []byte) ([]byte, bool) {
+	k := string(key)
+	if e, ok := t.writes[k]; ok {
+		if e == nil || e.expired(time.Now()) {
+			return nil, false
+		}
+		return append([]byte{}, e.val...), true
+	}
+	return t.store.get(k)
+}
+
+func (t *memoryTxn) Delete(key []byte) error {
+	t.stage(string(key), nil)
+	return nil
+}
+
+func (t *memoryTxn) stage(k string, e *memoryEntry) {
+	if _, ok := t.writes[k]; !ok {
+		t.order = append(t.order, k)
+	}
+	t.writes[k] = e
+}
+
+// NewMemoryStore initializes an in-memory storage
+func NewMemoryStore() Store {
+	store := &MemoryStore{
+		mutex:   new(sync.RWMutex),
+		entries: make(map[string]*memoryEntry),
+		watch:   newWatchHub(),
+		quit:    make(chan struct{}, 1),
+	}
+	store.Init()
+	return store
+}
@@ -0,0 +1,104 @@
+/* Copyright 2019 zigma authors
+ * This file is part of the zigma library.
+ *
+ * The zigma library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The zigma library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package store
+
+import (
+	"bytes"
+	"sort"
+	"time"
+)
+
+// MemoryIterator implements the iterator interface
+type MemoryIterator struct {
+	opts  IteratorOptions
+	items []*memoryEntry
+	start int
+	curr  int
+}
+
+// MemoryIteratorItem implements the iterator item interface
+type MemoryIteratorItem struct {
+	entry    *memoryEntry
+	keysOnly bool
+}
+
+// Seek would seek to the provided key if present, or the key following it in
+// the order of iteration, and rewinds the iterator cursor to the first key in
+// range if the key is nil
+func (m *MemoryIterator) Seek(key []byte) {
+	m.curr = -1
+	if !m.opts.Reverse {
+		m.start = sort.Search(len(m.items), func(i int) bool {
+			return bytes.Compare(m.items[i].key, key) >= 0
+		})
+		return
+	}
+	if key == nil {
+		m.start = len(m.items) - 1
+		return
+	}
+	m.start = sort.Search(len(m.items), func(i int) bool {
+		return bytes.Compare(m.items[i].key, key) > 0
+	}) - 1
+}
+
+// Next returns the true if next item is available, returns
+// false when iteration is done
+func (m *MemoryIterator) Next() bool {
+	m.curr++
+	if m.opts.Limit > 0 && m.curr >= m.opts.Limit {
+		return false
+	}
+	i := m.index()
+	return i >= 0 && i < len(m.items)
+}
+
+// Item returns the current key-value item
+func (m *MemoryIterator) Item() Item {
+	return &MemoryIteratorItem{m.items[m.index()], m.opts.KeysOnly}
+}
+
+// Done releases the snapshot held by the iterator
+func (m *MemoryIterator) Done() {
+	m.items = nil
+}
+
+func (m *MemoryIterator) index() int {
+	if m.opts.Reverse {
+		return m.start - m.curr
+	}
+	return m.start + m.curr
+}
+
+// Key returns the item key name
+func (m *MemoryIteratorItem) Key() []byte {
+	return m.entry.key
+}
+
+// Value returns the item key value
+func (m *MemoryIteratorItem) Value() []byte {
+	if m.keysOnly {
+		return nil
+	}
+	return append([]byte{}, m.entry.val...)
+}
+
+// TTL returns the item key expiration time
+func (m *MemoryIteratorItem) TTL() time.Time {
+	return m.entry.expiresAt
+}
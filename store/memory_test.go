@@ -18,42 +18,31 @@
 package store_test
 
 import (
-	"os"
-	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/store"
 )
 
-func TestBadgerStoreIterator(t *testing.T) {
-	db, dir := newBadgerDB(t)
-	defer os.RemoveAll(dir)
-	defer db.Close()
-
-	ks := make([][]byte, 30)
-	vs := make([][]byte, len(ks))
-	var i int
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func(t *testing.T) (store.Store, func()) {
+		db := store.NewMemoryStore()
+		return db, db.Close
+	})
+}
 
-	prefix := []byte{0x61, 0x3a, 0x62, 0x3a}
+func TestMemoryStoreSize(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
 
-	for i := 0; i < len(ks); i++ {
-		s := strconv.Itoa(10 + i)
-		k := append(prefix, s...)
-		v := []byte(s)
+	assert.Zero(t, db.Size())
 
-		ks[i] = k
-		vs[i] = v
-		db.Set(k, v, 0)
-	}
+	db.Set([]byte("key"), []byte("value"), 0)
+	assert.Equal(t, int64(8), db.Size())
 
-	iter := db.Iterate(prefix)
-	defer iter.Done()
+	db.Set([]byte("key"), []byte("v"), 0)
+	assert.Equal(t, int64(4), db.Size())
 
-	for iter.Next() {
-		item := iter.Item()
-		assert.Equal(t, ks[i], item.Key())
-		assert.Equal(t, vs[i], item.Value())
-		assert.Zero(t, item.TTL())
-		i++
-	}
+	db.Delete([]byte("key"))
+	assert.Zero(t, db.Size())
 }
@@ -0,0 +1,278 @@
+/* Copyright 2019 zigma authors
+ * This file is part of the zigma library.
+ *
+ * The zigma library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The zigma library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+ */
+package store
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// the byte closing the name of a namespace in the keys of its parent
+const nsSeparator = 0x21
+
+// the number of keys deleted in a single transaction by Drop
+const dropBatchSize = 256
+
+// ErrNamespaceBackup is returned when backing up or restoring a namespace, the
+// parent store is backed up as a whole
+var ErrNamespaceBackup = errors.New("namespaces are backed up with their parent store")
+
+// NamespaceStore implements a store isolated to the keys of its parent which
+// start with the name of the namespace followed by the separator. Keys read
+// from the namespace have the prefix stripped, namespaces can be nested
+type NamespaceStore struct {
+	parent Store
+	prefix []byte
+}
+
+// Namespace returns the namespace of the parent store with the name, names
+// must be non-empty and must not contain the separator '!'
+func Namespace(parent Store, name string) *NamespaceStore {
+	if len(name) == 0 || bytes.IndexByte([]byte(name), nsSeparator) >= 0 {
+		panic("store: invalid namespace name " + name)
+	}
+	prefix := make([]byte, len(name)+1)
+	copy(prefix, name)
+	prefix[len(name)] = nsSeparator
+	return &NamespaceStore{parent: parent, prefix: prefix}
+}
+
+// Namespaces lists the names of the namespaces holding keys in the store
+func Namespaces(s Store) []string {
+	iter := s.IterateWith(IteratorOptions{KeysOnly: true})
+	defer iter.Done()
+
+	var names []string
+	for iter.Next() {
+		key := iter.Item().Key()
+		i := bytes.IndexByte(key, nsSeparator)
+		if i <= 0 {
+			continue
+		}
+		names = append(names, string(key[:i]))
+		// skip the rest of the namespace
+		next := make([]byte, i+1)
+		copy(next, key[:i])
+		next[i] = nsSeparator + 1
+		iter.Seek(next)
+	}
+	return names
+}
+
+// Init is a no-op, the parent store is initialized by its owner
+func (n *NamespaceStore) Init() {}
+
+// Size returns the number of bytes held by the keys and values of the
+// namespace
+func (n *NamespaceStore) Size() int64 {
+	var size int64
+	iter := n.parent.Iterate(n.prefix)
+	for iter.Next() {
+		item := iter.Item()
+		size += int64(len(item.Key()) + len(item.Value()))
+	}
+	iter.Done()
+	return size
+}
+
+// Set sets value to the namespace
+func (n *NamespaceStore) Set(key, val []byte, ttl time.Duration) error {
+	return n.parent.Set(n.key(key), val, ttl)
+}
+
+// Get retrieves value from the namespace
+func (n *NamespaceStore) Get(key []byte) ([]byte, bool) {
+	return n.parent.Get(n.key(key))
+}
+
+// GetVersioned retrieves value from the namespace along with its version
+func (n *NamespaceStore) GetVersioned(key []byte) ([]byte, uint64, bool) {
+	return n.parent.GetVersioned(n.key(key))
+}
+
+// GetWithTTL retrieves value from the namespace along with its expiration time
+func (n *NamespaceStore) GetWithTTL(key []byte) ([]byte, time.Time, bool) {
+	return n.parent.GetWithTTL(n.key(key))
+}
+
+// CompareAndSet sets value to the namespace if the version of the key matches
+func (n *NamespaceStore) CompareAndSet(key []byte, version uint64, val []byte, ttl time.Duration) error {
+	return n.parent.CompareAndSet(n.key(key), version, val, ttl)
+}
+
+// Delete removes an existing key from the namespace
+func (n *NamespaceStore) Delete(key []byte) error {
+	return n.parent.Delete(n.key(key))
+}
+
+// Update runs the function in a read-write transaction of the parent store
+func (n *NamespaceStore) Update(fn func(Txn) error) error {
+	return n.parent.Update(func(txn Txn) error {
+		return fn(n.Txn(txn))
+	})
+}
+
+// Txn scopes a transaction of the parent store to the namespace, so that a
+// single transaction can write to several namespaces of a store
+func (n *NamespaceStore) Txn(txn Txn) Txn {
+	return &namespaceTxn{txn, n}
+}
+
+// Iterate iterates the key-value pairs of the namespace with the prefix
+func (n *NamespaceStore) Iterate(key []byte) Iterator {
+	return n.IterateWith(IteratorOptions{Prefix: key})
+}
+
+// IterateWith iterates the key-value pairs of the namespace in range
+func (n *NamespaceStore) IterateWith(opts IteratorOptions) Iterator {
+	opts.Prefix = n.key(opts.Prefix)
+	if opts.Start != nil {
+		opts.Start = n.key(opts.Start)
+	}
+	if opts.End != nil {
+		opts.End = n.key(opts.End)
+	}
+	return &namespaceIterator{n.parent.IterateWith(opts), n}
+}
+
+// Backup is not supported by namespaces
+func (n *NamespaceStore) Backup(w io.Writer, since uint64) (uint64, error) {
+	return 0, ErrNamespaceBackup
+}
+
+// Restore is not supported by namespaces
+func (n *NamespaceStore) Restore(r io.Reader) error {
+	return ErrNamespaceBackup
+}
+
+// Watch delivers the events of the keys of the namespace with the prefix, the
+// prefix of the namespace is stripped from the keys of the events
+func (n *NamespaceStore) Watch(prefix []byte) (<-chan Event, func()) {
+	in, stop := n.parent.Watch(n.key(prefix))
+	out := make(chan Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(out)
+		for ev := range in {
+			ev.Key = ev.Key[len(n.prefix):]
+			select {
+			case out <- ev:
+			case <-done:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return out, func() {
+		once.Do(func() {
+			close(done)
+			stop()
+		})
+	}
+}
+
+// Drop removes every key of the namespace
+func (n *NamespaceStore) Drop() error {
+	for {
+		var keys [][]byte
+		iter := n.parent.IterateWith(IteratorOptions{
+			Prefix:   n.prefix,
+			KeysOnly: true,
+			Limit:    dropBatchSize,
+		})
+		for iter.Next() {
+			keys = append(keys, append([]byte{}, iter.Item().Key()...))
+		}
+		iter.Done()
+
+		if len(keys) == 0 {
+			return nil
+		}
+		err := n.parent.Update(func(txn Txn) error {
+			for _, key := range keys {
+				if err := txn.Delete(key); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Close is a no-op, the parent store is closed by its owner
+func (n *NamespaceStore) Close() {}
+
+// key composes the key of the parent store, the prefix is copied so that keys
+// never share the backing array of the prefix
+func (n *NamespaceStore) key(key []byte) []byte {
+	b := make([]byte, len(n.prefix)+len(key))
+	copy(b, n.prefix)
+	copy(b[len(n.prefix):], key)
+	return b
+}
+
+// namespaceTxn scopes a transaction to a namespace
+type namespaceTxn struct {
+	txn Txn
+	ns  *NamespaceStore
+}
+
+func (t *namespaceTxn) Set(key, val []byte, ttl time.Duration) error {
+	return t.txn.Set(t.ns.key(key), val, ttl)
+}
+
+func (t *namespaceTxn) Get(key []byte) ([]byte, bool) {
+	return t.txn.Get(t.ns.key(key))
+}
+
+func (t *namespaceTxn) Delete(key []byte) error {
+	return t.txn.Delete(t.ns.key(key))
+}
+
+// namespaceIterator strips the prefix of the namespace from the keys
+type namespaceIterator struct {
+	Iterator
+	ns *NamespaceStore
+}
+
+func (i *namespaceIterator) Seek(key []byte) {
+	if key == nil {
+		i.Iterator.Seek(nil)
+		return
+	}
+	i.Iterator.Seek(i.ns.key(key))
+}
+
+func (i *namespaceIterator) Item() Item {
+	return &namespaceItem{i.Iterator.Item(), len(i.ns.prefix)}
+}
+
+// namespaceItem strips the prefix of the namespace from the key
+type namespaceItem struct {
+	Item
+	n int
+}
+
+func (i *namespaceItem) Key() []byte {
+	return i.Item.Key()[i.n:]
+}
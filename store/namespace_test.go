@@ -0,0 +1,104 @@
+/* Copyright 2019 zigma authors
+ * This file is part of the zigma library.
+ *
+ * The zigma library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The zigma library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+ */
+package store_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/store"
+)
+
+func TestNamespaceStore(t *testing.T) {
+	testStore(t, func(t *testing.T) (store.Store, func()) {
+		db := store.NewMemoryStore()
+		return store.Namespace(db, "ns"), db.Close
+	})
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	a := store.Namespace(db, "a")
+	b := store.Namespace(db, "b")
+	k := []byte("key")
+
+	assert.NoError(t, a.Set(k, []byte("a"), 0))
+	assert.NoError(t, b.Set(k, []byte("b"), 0))
+
+	v, ok := a.Get(k)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("a"), v)
+	v, ok = db.Get([]byte("b!key"))
+	assert.True(t, ok)
+	assert.Equal(t, []byte("b"), v)
+
+	iter := a.Iterate(nil)
+	assert.True(t, iter.Next())
+	assert.Equal(t, k, iter.Item().Key())
+	assert.False(t, iter.Next())
+	iter.Done()
+
+	nested := store.Namespace(a, "c")
+	assert.NoError(t, nested.Set(k, k, 0))
+	_, ok = db.Get([]byte("a!c!key"))
+	assert.True(t, ok)
+
+	assert.NoError(t, db.Set([]byte("raw"), k, 0))
+	assert.Equal(t, []string{"a", "b"}, store.Namespaces(db))
+	assert.Equal(t, []string{"c"}, store.Namespaces(a))
+
+	assert.NoError(t, a.Drop())
+	_, ok = a.Get(k)
+	assert.False(t, ok)
+	_, ok = nested.Get(k)
+	assert.False(t, ok)
+	_, ok = b.Get(k)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"b"}, store.Namespaces(db))
+}
+
+func TestNamespaceUpdate(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	a := store.Namespace(db, "a")
+	b := store.Namespace(db, "b")
+	k := []byte("key")
+
+	err := db.Update(func(txn store.Txn) error {
+		if err := a.Txn(txn).Set(k, k, 0); err != nil {
+			return err
+		}
+		return b.Txn(txn).Set(k, k, 0)
+	})
+	assert.NoError(t, err)
+
+	_, ok := a.Get(k)
+	assert.True(t, ok)
+	_, ok = b.Get(k)
+	assert.True(t, ok)
+}
+
+func TestNamespaceName(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	assert.Panics(t, func() { store.Namespace(db, "") })
+	assert.Panics(t, func() { store.Namespace(db, "a!b") })
+}
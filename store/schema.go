@@ -0,0 +1,103 @@
+/* Copyright 2019 zigma authors
+ * This file is part of the zigma library.
+ *
+ * The zigma library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The zigma library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// the namespace holding the version records of the schemas
+const schemaNamespace = "schema"
+
+// Errors for the schema versioning
+var (
+	ErrSchemaTooNew   = errors.New("database written by a newer storage format")
+	ErrSchemaRecord   = errors.New("malformed schema version record")
+	ErrMigrationOrder = errors.New("migrations are not in ascending version order")
+)
+
+// Migration upgrades the keys of a schema from the previous version to its
+// version. Migrations may be interrupted and run again, they are expected to
+// be idempotent
+type Migration struct {
+	Version     uint64
+	Description string
+	Migrate     func(Store) error
+}
+
+// SchemaVersion returns the version recorded for a schema, false if no version
+// has been recorded yet
+func SchemaVersion(s Store, name string) (uint64, bool, error) {
+	b, ok := Namespace(s, schemaNamespace).Get([]byte(name))
+	if !ok {
+		return 0, false, nil
+	}
+	v, n := binary.Uvarint(b)
+	if n <= 0 || n != len(b) {
+		return 0, true, ErrSchemaRecord
+	}
+	return v, true, nil
+}
+
+// Migrate brings a schema up to the version of its latest migration. The
+// migrations following the recorded version are run in order, and the version
+// is recorded after each one, so that an interrupted upgrade resumes from the
+// last completed migration. A schema without a version record is at version 0.
+// Returns ErrSchemaTooNew if the recorded version is newer than the latest
+// migration, the keys are left untouched
+func Migrate(s Store, name string, migrations []Migration) error {
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			return ErrMigrationOrder
+		}
+	}
+	current, _, err := SchemaVersion(s, name)
+	if err != nil {
+		return err
+	}
+	var latest uint64
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+	if current > latest {
+		return ErrSchemaTooNew
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if m.Migrate != nil {
+			if err := m.Migrate(s); err != nil {
+				return err
+			}
+		}
+		if err := setSchemaVersion(s, name, m.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setSchemaVersion records the version of a schema, the record never expires
+func setSchemaVersion(s Store, name string, version uint64) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, version)
+	return Namespace(s, schemaNamespace).Set([]byte(name), buf[:n], 0)
+}
@@ -0,0 +1,96 @@
+/* Copyright 2019 zigma authors
+ * This file is part of the zigma library.
+ *
+ * The zigma library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The zigma library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+ */
+package store_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/store"
+)
+
+func TestMigrate(t *testing.T) {
+	db := store.NewMemoryStore()
+	defer db.Close()
+
+	_, ok, err := store.SchemaVersion(db, "app")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	var (
+		ran   []uint64
+		fail  = errors.New("fail")
+		fails = true
+	)
+	step := func(v uint64) func(store.Store) error {
+		return func(s store.Store) error {
+			if v == 3 && fails {
+				return fail
+			}
+			ran = append(ran, v)
+			return s.Set([]byte{byte(v)}, nil, 0)
+		}
+	}
+	migrations := []store.Migration{
+		{Version: 1, Migrate: step(1)},
+		{Version: 2, Migrate: step(2)},
+		{Version: 3, Migrate: step(3)},
+	}
+
+	// an interrupted upgrade keeps the version of the last completed migration
+	assert.Equal(t, fail, store.Migrate(db, "app", migrations))
+	v, ok, err := store.SchemaVersion(db, "app")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), v)
+
+	fails = false
+	assert.NoError(t, store.Migrate(db, "app", migrations))
+	assert.Equal(t, []uint64{1, 2, 3}, ran)
+	v, _, _ = store.SchemaVersion(db, "app")
+	assert.Equal(t, uint64(3), v)
+
+	// migrations are run once
+	assert.NoError(t, store.Migrate(db, "app", migrations))
+	assert.Equal(t, []uint64{1, 2, 3}, ran)
+
+	// an older version refuses the database
+	assert.Equal(t, store.ErrSchemaTooNew, store.Migrate(db, "app", migrations[:2]))
+
+	// schemas are versioned independently
+	assert.NoError(t, store.Migrate(db, "other", migrations[:1]))
+	v, _, _ = store.SchemaVersion(db, "other")
+	assert.Equal(t, uint64(1), v)
+
+	unordered := []store.Migration{{Version: 2}, {Version: 1}}
+	assert.Equal(t, store.ErrMigrationOrder, store.Migrate(db, "new", unordered))
+}
+
+func TestSchemaVersionProtected(t *testing.T) {
+	db := store.NewBoundedStore(store.NewMemoryStore(), store.BoundedOptions{Capacity: 64})
+	defer db.Close()
+
+	assert.NoError(t, store.Migrate(db, "app", []store.Migration{{Version: 1}}))
+	for i := 0; i < 16; i++ {
+		assert.NoError(t, db.Set([]byte{byte(i)}, make([]byte, 16), 0))
+	}
+	v, ok, err := store.SchemaVersion(db, "app")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), v)
+}
@@ -0,0 +1,333 @@
+/* Copyright 2019 zigma authors
+ * This file is part of the zigma library.
+ *
+ * The zigma library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The zigma library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats encapsulates the usage of a store broken down by namespace, keys which
+// do not belong to a namespace are reported under the empty name
+type Stats struct {
+	Time       time.Time        `json:"time"`
+	Window     time.Duration    `json:"window"`     // the window of the keys counted as expiring soon
+	Size       int64            `json:"size"`       // the bytes reported by the store, on disk for badger
+	Keys       int              `json:"keys"`       // the number of keys of every namespace
+	Bytes      int64            `json:"bytes"`      // the bytes held by the keys and values of every namespace
+	Namespaces []NamespaceStats `json:"namespaces"` // the namespaces sorted by name
+}
+
+// NamespaceStats encapsulates the usage of a namespace, the operation counters
+// are only kept by stores wrapping a StatsStore
+type NamespaceStats struct {
+	Name         string `json:"name"`
+	Keys         int    `json:"keys"`
+	Bytes        int64  `json:"bytes"`
+	ExpiringSoon int    `json:"expiring_soon"`
+	OpCounters
+}
+
+// OpCounters encapsulates the operations made on the keys of a namespace
+type OpCounters struct {
+	Reads   uint64 `json:"reads"`   // point reads and keys visited by iterators
+	Writes  uint64 `json:"writes"`  // keys written, in or out of transactions
+	Deletes uint64 `json:"deletes"` // keys deleted, expiration is not counted
+}
+
+// WriteJSON writes the stats in json format
+func (s *Stats) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// wrapper is implemented by the stores which wrap another store without
+// changing its keys
+type wrapper interface {
+	unwrap() Store
+}
+
+// ReadStats walks the keys of a store to report the usage of its namespaces,
+// keys expiring within the window are counted as expiring soon. Encrypted and
+// bounded stores are walked through to the underlying store, so that bytes
+// are reported as they are held on disk
+func ReadStats(s Store, window time.Duration) Stats {
+	var counters map[string]OpCounters
+	for {
+		if st, ok := s.(*StatsStore); ok && counters == nil {
+			counters = st.Counters()
+		}
+		w, ok := s.(wrapper)
+		if !ok {
+			break
+		}
+		s = w.unwrap()
+	}
+
+	var (
+		now   = time.Now()
+		stats = Stats{Time: now.UTC(), Window: window, Size: s.Size()}
+		names = make(map[string]*NamespaceStats)
+	)
+	get := func(name string) *NamespaceStats {
+		ns, ok := names[name]
+		if !ok {
+			ns = &NamespaceStats{Name: name}
+			names[name] = ns
+		}
+		return ns
+	}
+
+	iter := s.IterateWith(IteratorOptions{})
+	for iter.Next() {
+		item := iter.Item()
+		ns := get(namespaceOf(item.Key()))
+		size := int64(len(item.Key()) + len(item.Value()))
+		ns.Keys++
+		ns.Bytes += size
+		stats.Keys++
+		stats.Bytes += size
+		if ttl := item.TTL(); !ttl.IsZero() && ttl.Before(now.Add(window)) {
+			ns.ExpiringSoon++
+		}
+	}
+	iter.Done()
+
+	for name, c := range counters {
+		get(name).OpCounters = c
+	}
+	for _, ns := range names {
+		stats.Namespaces = append(stats.Namespaces, *ns)
+	}
+	sort.Slice(stats.Namespaces, func(i, j int) bool {
+		return stats.Namespaces[i].Name < stats.Namespaces[j].Name
+	})
+	return stats
+}
+
+// namespaceOf returns the name of the outermost namespace of a key
+func namespaceOf(key []byte) string {
+	if i := bytes.IndexByte(key, nsSeparator); i > 0 {
+		return string(key[:i])
+	}
+	return ""
+}
+
+// StatsStore extends a store with counters of the reads, writes and deletes
+// made on the keys of every namespace
+type StatsStore struct {
+	Store
+	mutex    *sync.RWMutex
+	counters map[string]*opCounters
+}
+
+type opCounters struct {
+	reads, writes, deletes uint64
+}
+
+// Counters returns a copy of the operation counters by namespace
+func (s *StatsStore) Counters() map[string]OpCounters {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	out := make(map[string]OpCounters, len(s.counters))
+	for name, c := range s.counters {
+		out[name] = OpCounters{
+			Reads:   atomic.LoadUint64(&c.reads),
+			Writes:  atomic.LoadUint64(&c.writes),
+			Deletes: atomic.LoadUint64(&c.deletes),
+		}
+	}
+	return out
+}
+
+// Set stores a value and counts a write
+func (s *StatsStore) Set(key, val []byte, ttl time.Duration) error {
+	err := s.Store.Set(key, val, ttl)
+	if err == nil {
+		atomic.AddUint64(&s.of(key).writes, 1)
+	}
+	return err
+}
+
+// Get retrieves a value and counts a read
+func (s *StatsStore) Get(key []byte) ([]byte, bool) {
+	atomic.AddUint64(&s.of(key).reads, 1)
+	return s.Store.Get(key)
+}
+
+// GetVersioned retrieves a value along with its version and counts a read
+func (s *StatsStore) GetVersioned(key []byte) ([]byte, uint64, bool) {
+	atomic.AddUint64(&s.of(key).reads, 1)
+	return s.Store.GetVersioned(key)
+}
+
+// GetWithTTL retrieves a value along with its expiration time and counts a
+// read
+func (s *StatsStore) GetWithTTL(key []byte) ([]byte, time.Time, bool) {
+	atomic.AddUint64(&s.of(key).reads, 1)
+	return s.Store.GetWithTTL(key)
+}
+
+// CompareAndSet stores a value if the version of the key matches, and counts
+// a write if it does
+func (s *StatsStore) CompareAndSet(key []byte, version uint64, val []byte, ttl time.Duration) error {
+	err := s.Store.CompareAndSet(key, version, val, ttl)
+	if err == nil {
+		atomic.AddUint64(&s.of(key).writes, 1)
+	}
+	return err
+}
+
+// Delete removes a key and counts a delete
+func (s *StatsStore) Delete(key []byte) error {
+	err := s.Store.Delete(key)
+	if err == nil {
+		atomic.AddUint64(&s.of(key).deletes, 1)
+	}
+	return err
+}
+
+// Update runs the function in a read-write transaction of the underlying
+// store, the operations of the transaction are counted once it commits
+func (s *StatsStore) Update(fn func(Txn) error) error {
+	var st *statsTxn
+	err := s.Store.Update(func(txn Txn) error {
+		st = &statsTxn{txn: txn}
+		return fn(st)
+	})
+	if err == nil && st != nil {
+		for _, op := range st.ops {
+			c := s.of(op.key)
+			switch op.kind {
+			case opRead:
+				atomic.AddUint64(&c.reads, 1)
+			case opWrite:
+				atomic.AddUint64(&c.writes, 1)
+			case opDelete:
+				atomic.AddUint64(&c.deletes, 1)
+			}
+		}
+	}
+	return err
+}
+
+// Iterate iterates the keys with the prefix, and counts a read for every key
+// visited
+func (s *StatsStore) Iterate(key []byte) Iterator {
+	return s.IterateWith(IteratorOptions{Prefix: key})
+}
+
+// IterateWith iterates the keys in range, and counts a read for every key
+// visited
+func (s *StatsStore) IterateWith(opts IteratorOptions) Iterator {
+	return &statsIterator{s.Store.IterateWith(opts), s}
+}
+
+func (s *StatsStore) unwrap() Store {
+	return s.Store
+}
+
+// of returns the counters of the namespace of a key
+func (s *StatsStore) of(key []byte) *opCounters {
+	name := namespaceOf(key)
+
+	s.mutex.RLock()
+	c, ok := s.counters[name]
+	s.mutex.RUnlock()
+	if ok {
+		return c
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if c, ok = s.counters[name]; !ok {
+		c = new(opCounters)
+		s.counters[name] = c
+	}
+	return c
+}
+
+type opKind int
+
+const (
+	opRead opKind = iota
+	opWrite
+	opDelete
+)
+
+type statsOp struct {
+	key  []byte
+	kind opKind
+}
+
+// statsTxn records the operations of a transaction
+type statsTxn struct {
+	txn Txn
+	ops []statsOp
+}
+
+func (t *statsTxn) Set(key, val []byte, ttl time.Duration) error {
+	if err := t.txn.Set(key, val, ttl); err != nil {
+		return err
+	}
+	t.ops = append(t.ops, statsOp{append([]byte{}, key...), opWrite})
+	return nil
+}
+
+func (t *statsTxn) Get(key []byte) ([]byte, bool) {
+	t.ops = append(t.ops, statsOp{append([]byte{}, key...), opRead})
+	return t.txn.Get(key)
+}
+
+func (t *statsTxn) Delete(key []byte) error {
+	if err := t.txn.Delete(key); err != nil {
+		return err
+	}
+	t.ops = append(t.ops, statsOp{append([]byte{}, key...), opDelete})
+	return nil
+}
+
+// statsIterator counts the keys visited by an iterator
+type statsIterator struct {
+	Iterator
+	s *StatsStore
+}
+
+func (i *statsIterator) Next() bool {
+	if !i.Iterator.Next() {
+		return false
+	}
+	atomic.AddUint64(&i.s.of(i.Iterator.Item().Key()).reads, 1)
+	return true
+}
+
+// NewStatsStore wraps a store with operation counters
+func NewStatsStore(store Store) *StatsStore {
+	return &StatsStore{
+		Store:    store,
+		mutex:    new(sync.RWMutex),
+		counters: make(map[string]*opCounters),
+	}
+}
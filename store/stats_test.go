@@ -0,0 +1,106 @@
+/* Copyright 2019 zigma authors
+ * This file is part of the zigma library.
+ *
+ * The zigma library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The zigma library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+ */
+package store_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/store"
+)
+
+func TestStatsStore(t *testing.T) {
+	testStore(t, func(t *testing.T) (store.Store, func()) {
+		db := store.NewStatsStore(store.NewMemoryStore())
+		return db, db.Close
+	})
+}
+
+func TestStatsStoreCounters(t *testing.T) {
+	db := store.NewStatsStore(store.NewMemoryStore())
+	defer db.Close()
+
+	a := store.Namespace(db, "a")
+	b := store.Namespace(db, "b")
+	k := []byte("key")
+
+	assert.NoError(t, a.Set(k, k, 0))
+	assert.NoError(t, a.Set([]byte("soon"), k, time.Minute))
+	assert.NoError(t, b.Set(k, []byte("value"), time.Hour*2))
+	assert.NoError(t, db.Set(k, k, 0))
+	a.Get(k)
+	b.GetWithTTL(k)
+	assert.NoError(t, a.Delete([]byte("missing")))
+	assert.NoError(t, b.Update(func(txn store.Txn) error {
+		txn.Get(k)
+		return txn.Set([]byte("txn"), k, 0)
+	}))
+
+	iter := a.Iterate(nil)
+	for iter.Next() {
+	}
+	iter.Done()
+
+	c := db.Counters()
+	assert.Equal(t, store.OpCounters{Reads: 3, Writes: 2, Deletes: 1}, c["a"])
+	assert.Equal(t, store.OpCounters{Reads: 2, Writes: 2}, c["b"])
+	assert.Equal(t, store.OpCounters{Writes: 1}, c[""])
+}
+
+func TestReadStats(t *testing.T) {
+	mem := store.NewMemoryStore()
+	db := store.NewBoundedStore(store.NewStatsStore(mem), store.BoundedOptions{Capacity: 1 << 20})
+	defer db.Close()
+
+	a := store.Namespace(db, "a")
+	assert.NoError(t, a.Set([]byte("k1"), []byte("v1"), 0))
+	assert.NoError(t, a.Set([]byte("k2"), []byte("v2"), time.Minute))
+	assert.NoError(t, store.Namespace(db, "b").Set([]byte("k"), []byte("value"), time.Hour*2))
+	assert.NoError(t, db.Set([]byte("root"), nil, 0))
+
+	stats := store.ReadStats(db, time.Hour)
+	assert.Equal(t, mem.Size(), stats.Size)
+	assert.Equal(t, 4, stats.Keys)
+	assert.Equal(t, mem.Size(), stats.Bytes)
+	assert.Len(t, stats.Namespaces, 3)
+
+	root, ns, nb := stats.Namespaces[0], stats.Namespaces[1], stats.Namespaces[2]
+	assert.Equal(t, "", root.Name)
+	assert.Equal(t, 1, root.Keys)
+	assert.Equal(t, int64(4), root.Bytes)
+
+	assert.Equal(t, "a", ns.Name)
+	assert.Equal(t, 2, ns.Keys)
+	assert.Equal(t, int64(12), ns.Bytes)
+	assert.Equal(t, 1, ns.ExpiringSoon)
+	assert.Equal(t, uint64(2), ns.Writes)
+
+	assert.Equal(t, "b", nb.Name)
+	assert.Equal(t, 0, nb.ExpiringSoon)
+
+	// the walk of the store is not counted as reads
+	assert.Zero(t, store.ReadStats(db, time.Hour).Namespaces[1].Reads)
+
+	var buf bytes.Buffer
+	assert.NoError(t, stats.WriteJSON(&buf))
+	var out store.Stats
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.Equal(t, stats.Namespaces, out.Namespaces)
+}
@@ -17,19 +17,126 @@
 
 package store
 
-import "time"
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrVersionMismatch is returned by CompareAndSet when the key was written
+// since its version was read
+var ErrVersionMismatch = errors.New("version mismatch")
 
 // Store is the interface for implementing the basic storage mechanism
 type Store interface {
+	// Init starts the background maintenance of the store
 	Init()
+	// Size returns the number of bytes held by the store
 	Size() int64
-	Set(key, val []byte, ttl time.Duration)
+	// Set writes the value of a key, the key never expires if the ttl is not
+	// positive
+	Set(key, val []byte, ttl time.Duration) error
+	// Get reads the value of a key
 	Get(key []byte) (data []byte, found bool)
-	Delete(key []byte)
+	// GetVersioned reads the value of a key along with its version, which is
+	// passed to CompareAndSet
+	GetVersioned(key []byte) (data []byte, version uint64, found bool)
+	// GetWithTTL reads the value of a key along with its expiration time, the
+	// zero time if the key never expires
+	GetWithTTL(key []byte) (data []byte, expiresAt time.Time, found bool)
+	// CompareAndSet writes the value only if the version of the key is still
+	// the version read by GetVersioned, version 0 expects the key to be absent;
+	// returns ErrVersionMismatch otherwise
+	CompareAndSet(key []byte, version uint64, val []byte, ttl time.Duration) error
+	// Delete removes a key
+	Delete(key []byte) error
+	// Update runs fn in a read-write transaction, the writes of fn are applied
+	// atomically if fn returns nil and discarded otherwise
+	Update(fn func(Txn) error) error
+	// Iterate visits the keys with the prefix, the TTL of an item is the
+	// expiration time of the key, the zero time if the key never expires
 	Iterate(key []byte) Iterator
+	// IterateWith visits the keys selected by the options
+	IterateWith(opts IteratorOptions) Iterator
+	// Backup writes the keys written at or after the since version from a
+	// consistent snapshot, while the store keeps serving reads and writes.
+	// Returns the version to pass as since to the next incremental backup, a
+	// backup since 0 is a full backup
+	Backup(w io.Writer, since uint64) (uint64, error)
+	// Restore loads the keys written by Backup, the store must not be used by
+	// other transactions while the backup loads. The restored keys are not
+	// delivered to watches
+	Restore(r io.Reader) error
+	// Watch delivers the events of the keys with the prefix, the channel is
+	// closed when the returned function cancels the watch
+	Watch(prefix []byte) (<-chan Event, func())
+	// Close releases the resources held by the store
 	Close()
 }
 
+// Txn is the interface for implementing a read-write transaction, the writes
+// of a transaction are applied atomically when the transaction commits
+type Txn interface {
+	Set(key, val []byte, ttl time.Duration) error
+	Get(key []byte) (data []byte, found bool)
+	Delete(key []byte) error
+}
+
+// IteratorOptions narrows down the keys visited by an iterator
+type IteratorOptions struct {
+	// Prefix limits the iteration to the keys with the prefix
+	Prefix []byte
+	// Start is the inclusive lower bound of the keys
+	Start []byte
+	// End is the exclusive upper bound of the keys
+	End []byte
+	// Reverse visits the keys in descending order
+	Reverse bool
+	// KeysOnly skips fetching values, Item.Value returns nil
+	KeysOnly bool
+	// PrefetchSize is the number of values fetched ahead of the cursor
+	PrefetchSize int
+	// Limit stops the iteration after the number of items, 0 means no limit
+	Limit int
+}
+
+// InRange checks if the key is within the prefix and bounds of the options
+func (o IteratorOptions) InRange(key []byte) bool {
+	if !bytes.HasPrefix(key, o.Prefix) {
+		return false
+	}
+	if o.Start != nil && bytes.Compare(key, o.Start) < 0 {
+		return false
+	}
+	return o.End == nil || bytes.Compare(key, o.End) < 0
+}
+
+// lower returns the smallest key in range, the greater of the prefix and the
+// start bound
+func (o IteratorOptions) lower() []byte {
+	if bytes.Compare(o.Start, o.Prefix) > 0 {
+		return o.Start
+	}
+	return o.Prefix
+}
+
+// upper returns the exclusive upper bound of the keys in range, the lesser of
+// the end bound and the key following the prefix; nil if unbounded
+func (o IteratorOptions) upper() []byte {
+	var next []byte
+	for i := len(o.Prefix) - 1; i >= 0; i-- {
+		if o.Prefix[i] < 0xff {
+			next = append(append([]byte{}, o.Prefix[:i]...), o.Prefix[i]+1)
+			break
+		}
+	}
+	if o.End == nil || (next != nil && bytes.Compare(next, o.End) < 0) {
+		return next
+	}
+	return o.End
+}
+
 // Iterator is the interface for implementing iterator for key-value storage
 type Iterator interface {
 	Seek(key []byte)
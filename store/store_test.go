@@ -0,0 +1,495 @@
+/* Copyright 2019 zigma authors
+ * This file is part of the zigma library.
+ *
+ * The zigma library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The zigma library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package store_test
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zigmahq/zigma/store"
+)
+
+// storeFactory opens an empty store, and returns a function releasing it
+type storeFactory func(t *testing.T) (store.Store, func())
+
+// testStore runs the conformance tests every store implementation must pass
+func testStore(t *testing.T, open storeFactory) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, store.Store)
+	}{
+		{"SetEx", testStoreSetEx},
+		{"GetAfterSet", testStoreGetAfterSet},
+		{"DeleteAfterSet", testStoreDeleteAfterSet},
+		{"Iterator", testStoreIterator},
+		{"IteratorSeek", testStoreIteratorSeek},
+		{"IteratorTTL", testStoreIteratorTTL},
+		{"GetWithTTL", testStoreGetWithTTL},
+		{"IteratorRange", testStoreIteratorRange},
+		{"IteratorReverse", testStoreIteratorReverse},
+		{"IteratorKeysOnly", testStoreIteratorKeysOnly},
+		{"CompareAndSet", testStoreCompareAndSet},
+		{"CompareAndSetConcurrent", testStoreCompareAndSetConcurrent},
+		{"Watch", testStoreWatch},
+		{"Update", testStoreUpdate},
+		{"UpdateRollback", testStoreUpdateRollback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, done := open(t)
+			defer done()
+			tt.fn(t, db)
+		})
+	}
+	t.Run("BackupRestore", func(t *testing.T) {
+		testStoreBackupRestore(t, open)
+	})
+}
+
+func testStoreSetEx(t *testing.T, db store.Store) {
+	o := []byte{0x62}
+
+	db.Set(o, o, time.Second*2)
+
+	b, ok := db.Get(o)
+	assert.True(t, ok)
+	assert.Equal(t, o, b)
+
+	time.Sleep(time.Second*2 + time.Millisecond)
+
+	b, ok = db.Get(o)
+	assert.False(t, ok)
+	assert.Nil(t, b)
+}
+
+func testStoreGetAfterSet(t *testing.T, db store.Store) {
+	arr := make([][]byte, 500)
+
+	for i := 0; i < len(arr); i++ {
+		s := strconv.Itoa(i)
+		arr[i] = []byte(s)
+		db.Set(arr[i], arr[i], 0)
+	}
+	for i := 0; i < len(arr); i++ {
+		o, found := db.Get(arr[i])
+		assert.True(t, found)
+		assert.Equal(t, arr[i], o)
+	}
+}
+
+func testStoreDeleteAfterSet(t *testing.T, db store.Store) {
+	o := []byte{0x61}
+
+	b, ok := db.Get(o)
+	assert.False(t, ok)
+	assert.Nil(t, b)
+
+	assert.NoError(t, db.Set(o, o, 0))
+
+	b, ok = db.Get(o)
+	assert.True(t, ok)
+	assert.Equal(t, o, b)
+
+	assert.NoError(t, db.Delete(o))
+
+	b, ok = db.Get(o)
+	assert.False(t, ok)
+	assert.Nil(t, b)
+}
+
+// fillPrefix sets 30 keys under the prefix, and a key outside of the prefix
+func fillPrefix(db store.Store, prefix []byte) ([][]byte, [][]byte) {
+	ks := make([][]byte, 30)
+	vs := make([][]byte, len(ks))
+
+	for i := 0; i < len(ks); i++ {
+		s := strconv.Itoa(10 + i)
+		k := append(append([]byte{}, prefix...), s...)
+		v := []byte(s)
+
+		ks[i] = k
+		vs[i] = v
+		db.Set(k, v, 0)
+	}
+	db.Set([]byte{0x7a}, []byte{0x7a}, 0)
+	return ks, vs
+}
+
+func testStoreIterator(t *testing.T, db store.Store) {
+	prefix := []byte{0x61, 0x3a, 0x62, 0x3a}
+	ks, vs := fillPrefix(db, prefix)
+
+	iter := db.Iterate(prefix)
+	defer iter.Done()
+
+	var i int
+	for iter.Next() {
+		item := iter.Item()
+		assert.Equal(t, ks[i], item.Key())
+		assert.Equal(t, vs[i], item.Value())
+		assert.Zero(t, item.TTL())
+		i++
+	}
+	assert.Equal(t, len(ks), i)
+}
+
+func testStoreIteratorSeek(t *testing.T, db store.Store) {
+	prefix := []byte{0x61, 0x3a, 0x62, 0x3a}
+	ks, _ := fillPrefix(db, prefix)
+
+	iter := db.Iterate(prefix)
+	defer iter.Done()
+
+	iter.Seek(ks[10])
+	var i int
+	for iter.Next() {
+		assert.Equal(t, ks[10+i], iter.Item().Key())
+		i++
+	}
+	assert.Equal(t, len(ks)-10, i)
+
+	iter.Seek(nil)
+	assert.True(t, iter.Next())
+	assert.Equal(t, ks[0], iter.Item().Key())
+}
+
+func testStoreIteratorTTL(t *testing.T, db store.Store) {
+	k := []byte{0x74, 0x74, 0x6c}
+	db.Set(k, k, time.Hour)
+
+	iter := db.Iterate(k)
+	defer iter.Done()
+
+	assert.True(t, iter.Next())
+	ttl := iter.Item().TTL()
+	assert.True(t, ttl.After(time.Now().Add(time.Hour-time.Minute)))
+	assert.True(t, ttl.Before(time.Now().Add(time.Hour+time.Minute)))
+	assert.False(t, iter.Next())
+}
+
+func testStoreGetWithTTL(t *testing.T, db store.Store) {
+	k := []byte{0x67, 0x74, 0x74, 0x6c}
+	assert.NoError(t, db.Set(k, k, time.Hour))
+
+	v, ttl, ok := db.GetWithTTL(k)
+	assert.True(t, ok)
+	assert.Equal(t, k, v)
+	assert.True(t, ttl.After(time.Now().Add(time.Hour-time.Minute)))
+	assert.True(t, ttl.Before(time.Now().Add(time.Hour+time.Minute)))
+
+	assert.NoError(t, db.Set(k, k, 0))
+	_, ttl, ok = db.GetWithTTL(k)
+	assert.True(t, ok)
+	assert.True(t, ttl.IsZero())
+
+	assert.NoError(t, db.Delete(k))
+	_, _, ok = db.GetWithTTL(k)
+	assert.False(t, ok)
+}
+
+func testStoreIteratorRange(t *testing.T, db store.Store) {
+	prefix := []byte{0x61, 0x3a, 0x62, 0x3a}
+	ks, _ := fillPrefix(db, prefix)
+
+	iter := db.IterateWith(store.IteratorOptions{
+		Prefix: prefix,
+		Start:  ks[5],
+		End:    ks[20],
+	})
+	var i int
+	for iter.Next() {
+		assert.Equal(t, ks[5+i], iter.Item().Key())
+		i++
+	}
+	iter.Done()
+	assert.Equal(t, 15, i)
+
+	iter = db.IterateWith(store.IteratorOptions{Prefix: prefix, Start: ks[5], Limit: 3})
+	i = 0
+	for iter.Next() {
+		assert.Equal(t, ks[5+i], iter.Item().Key())
+		i++
+	}
+	iter.Done()
+	assert.Equal(t, 3, i)
+}
+
+func testStoreIteratorReverse(t *testing.T, db store.Store) {
+	prefix := []byte{0x61, 0x3a, 0x62, 0x3a}
+	ks, _ := fillPrefix(db, prefix)
+
+	iter := db.IterateWith(store.IteratorOptions{Prefix: prefix, Reverse: true})
+	var i int
+	for iter.Next() {
+		assert.Equal(t, ks[len(ks)-1-i], iter.Item().Key())
+		i++
+	}
+	assert.Equal(t, len(ks), i)
+
+	iter.Seek(ks[10])
+	assert.True(t, iter.Next())
+	assert.Equal(t, ks[10], iter.Item().Key())
+	assert.True(t, iter.Next())
+	assert.Equal(t, ks[9], iter.Item().Key())
+	iter.Done()
+
+	iter = db.IterateWith(store.IteratorOptions{
+		Prefix:  prefix,
+		Start:   ks[5],
+		End:     ks[20],
+		Reverse: true,
+	})
+	i = 0
+	for iter.Next() {
+		assert.Equal(t, ks[19-i], iter.Item().Key())
+		i++
+	}
+	iter.Done()
+	assert.Equal(t, 15, i)
+}
+
+func testStoreIteratorKeysOnly(t *testing.T, db store.Store) {
+	prefix := []byte{0x61, 0x3a, 0x62, 0x3a}
+	ks, _ := fillPrefix(db, prefix)
+
+	iter := db.IterateWith(store.IteratorOptions{Prefix: prefix, KeysOnly: true, PrefetchSize: 4})
+	defer iter.Done()
+
+	var i int
+	for iter.Next() {
+		assert.Equal(t, ks[i], iter.Item().Key())
+		assert.Nil(t, iter.Item().Value())
+		i++
+	}
+	assert.Equal(t, len(ks), i)
+}
+
+func testStoreCompareAndSet(t *testing.T, db store.Store) {
+	k := []byte{0x63, 0x61, 0x73}
+
+	_, version, ok := db.GetVersioned(k)
+	assert.False(t, ok)
+	assert.Zero(t, version)
+
+	assert.NoError(t, db.CompareAndSet(k, 0, []byte{0x01}, 0))
+	assert.Equal(t, store.ErrVersionMismatch, db.CompareAndSet(k, 0, []byte{0x02}, 0))
+
+	v, version, ok := db.GetVersioned(k)
+	assert.True(t, ok)
+	assert.NotZero(t, version)
+	assert.Equal(t, []byte{0x01}, v)
+
+	assert.NoError(t, db.CompareAndSet(k, version, []byte{0x03}, time.Hour))
+	assert.Equal(t, store.ErrVersionMismatch, db.CompareAndSet(k, version, []byte{0x04}, 0))
+
+	v, next, ok := db.GetVersioned(k)
+	assert.True(t, ok)
+	assert.NotEqual(t, version, next)
+	assert.Equal(t, []byte{0x03}, v)
+
+	// an unconditional write changes the version too
+	assert.NoError(t, db.Set(k, []byte{0x05}, 0))
+	assert.Equal(t, store.ErrVersionMismatch, db.CompareAndSet(k, next, []byte{0x06}, 0))
+}
+
+func testStoreCompareAndSetConcurrent(t *testing.T, db store.Store) {
+	k := []byte{0x63, 0x6e, 0x74}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				v, version, _ := db.GetVersioned(k)
+				n, _ := strconv.Atoi(string(v))
+				err := db.CompareAndSet(k, version, []byte(strconv.Itoa(n+1)), 0)
+				if err != store.ErrVersionMismatch {
+					assert.NoError(t, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	v, ok := db.Get(k)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("16"), v)
+}
+
+// nextEvent waits for an event of a watch
+func nextEvent(t *testing.T, ch <-chan store.Event) store.Event {
+	select {
+	case ev, ok := <-ch:
+		assert.True(t, ok)
+		return ev
+	case <-time.After(time.Second * 5):
+		t.Fatal("no event delivered")
+	}
+	return store.Event{}
+}
+
+func testStoreWatch(t *testing.T, db store.Store) {
+	prefix := []byte{0x77, 0x3a}
+	a := append(append([]byte{}, prefix...), 0x61)
+	b := append(append([]byte{}, prefix...), 0x62)
+	c := append(append([]byte{}, prefix...), 0x63)
+
+	ch, cancel := db.Watch(prefix)
+
+	assert.NoError(t, db.Set(a, a, time.Second))
+	assert.NoError(t, db.Set(b, b, 0))
+	assert.NoError(t, db.Set([]byte{0x78}, b, 0))
+	assert.NoError(t, db.Delete(b))
+	assert.NoError(t, db.Update(func(txn store.Txn) error {
+		return txn.Set(c, c, 0)
+	}))
+
+	ev := nextEvent(t, ch)
+	assert.Equal(t, store.EventSet, ev.Type)
+	assert.Equal(t, a, ev.Key)
+	assert.False(t, ev.ExpiresAt.IsZero())
+
+	ev = nextEvent(t, ch)
+	assert.Equal(t, store.EventSet, ev.Type)
+	assert.Equal(t, b, ev.Key)
+	assert.True(t, ev.ExpiresAt.IsZero())
+
+	ev = nextEvent(t, ch)
+	assert.Equal(t, store.EventDelete, ev.Type)
+	assert.Equal(t, b, ev.Key)
+
+	ev = nextEvent(t, ch)
+	assert.Equal(t, store.EventSet, ev.Type)
+	assert.Equal(t, c, ev.Key)
+
+	ev = nextEvent(t, ch)
+	assert.Equal(t, store.EventExpire, ev.Type)
+	assert.Equal(t, a, ev.Key)
+
+	cancel()
+	for range ch {
+	}
+}
+
+func testStoreUpdate(t *testing.T, db store.Store) {
+	a, b, c := []byte{0x61}, []byte{0x62}, []byte{0x63}
+	assert.NoError(t, db.Set(c, c, 0))
+
+	err := db.Update(func(txn store.Txn) error {
+		if err := txn.Set(a, a, 0); err != nil {
+			return err
+		}
+		if err := txn.Set(b, b, time.Hour); err != nil {
+			return err
+		}
+		if err := txn.Delete(c); err != nil {
+			return err
+		}
+		v, ok := txn.Get(a)
+		assert.True(t, ok)
+		assert.Equal(t, a, v)
+		_, ok = txn.Get(c)
+		assert.False(t, ok)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	v, ok := db.Get(a)
+	assert.True(t, ok)
+	assert.Equal(t, a, v)
+	v, ok = db.Get(b)
+	assert.True(t, ok)
+	assert.Equal(t, b, v)
+	_, ok = db.Get(c)
+	assert.False(t, ok)
+}
+
+func testStoreUpdateRollback(t *testing.T, db store.Store) {
+	a, b := []byte{0x61}, []byte{0x62}
+	assert.NoError(t, db.Set(b, b, 0))
+
+	abort := errors.New("abort")
+	err := db.Update(func(txn store.Txn) error {
+		if err := txn.Set(a, a, 0); err != nil {
+			return err
+		}
+		if err := txn.Delete(b); err != nil {
+			return err
+		}
+		return abort
+	})
+	assert.Equal(t, abort, err)
+
+	_, ok := db.Get(a)
+	assert.False(t, ok)
+	v, ok := db.Get(b)
+	assert.True(t, ok)
+	assert.Equal(t, b, v)
+}
+
+func testStoreBackupRestore(t *testing.T, open storeFactory) {
+	db, done := open(t)
+	defer done()
+
+	prefix := []byte{0x61, 0x3a, 0x62, 0x3a}
+	ks, vs := fillPrefix(db, prefix)
+	assert.NoError(t, db.Set([]byte{0x74}, []byte{0x74}, time.Hour))
+
+	var full bytes.Buffer
+	since, err := db.Backup(&full, 0)
+	if err == store.ErrNamespaceBackup {
+		t.Skip("backed up with the parent store")
+	}
+	assert.NoError(t, err)
+
+	k := []byte{0x6e}
+	assert.NoError(t, db.Set(k, k, 0))
+
+	var inc bytes.Buffer
+	_, err = db.Backup(&inc, since)
+	assert.NoError(t, err)
+	assert.True(t, inc.Len() < full.Len())
+
+	restored, rdone := open(t)
+	defer rdone()
+
+	assert.NoError(t, restored.Restore(&full))
+	for i := range ks {
+		v, ok := restored.Get(ks[i])
+		assert.True(t, ok)
+		assert.Equal(t, vs[i], v)
+	}
+	iter := restored.Iterate([]byte{0x74})
+	assert.True(t, iter.Next())
+	assert.True(t, iter.Item().TTL().After(time.Now()))
+	iter.Done()
+	_, ok := restored.Get(k)
+	assert.False(t, ok)
+
+	assert.NoError(t, restored.Restore(&inc))
+	v, ok := restored.Get(k)
+	assert.True(t, ok)
+	assert.Equal(t, k, v)
+}
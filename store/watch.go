@@ -0,0 +1,268 @@
+/* Copyright 2019 zigma authors
+ * This file is part of the zigma library.
+ *
+ * The zigma library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The zigma library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with the zigma library. If not, see <http://www.gnu.org/licenses/>.
+ */
+package store
+
+import (
+	"bytes"
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of change of a key
+type EventType int
+
+// Define the kinds of change of a key
+const (
+	EventSet EventType = iota
+	EventDelete
+	EventExpire
+)
+
+// Event is delivered to the watchers of a key when the key changes, the
+// expiration is zero unless the key was set with a ttl
+type Event struct {
+	Type      EventType
+	Key       []byte
+	ExpiresAt time.Time
+}
+
+// String returns the name of the event type
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventDelete:
+		return "delete"
+	case EventExpire:
+		return "expire"
+	}
+	return "unknown"
+}
+
+// watchHub delivers the events of the committed writes of a store to its
+// watchers. Keys set with a ttl under a watched prefix are tracked, and an
+// expire event is delivered once their expiration passes
+type watchHub struct {
+	mutex    *sync.Mutex
+	watchers map[*watcher]struct{}
+	expires  map[string]time.Time
+	queue    expiryQueue
+	timer    *time.Timer
+}
+
+// watcher queues the events of a prefix, so that slow readers never block the
+// writers of the store
+type watcher struct {
+	prefix []byte
+	ch     chan Event
+	mutex  *sync.Mutex
+	events []Event
+	signal chan struct{}
+	done   chan struct{}
+}
+
+func newWatchHub() *watchHub {
+	return &watchHub{
+		mutex:    new(sync.Mutex),
+		watchers: make(map[*watcher]struct{}),
+		expires:  make(map[string]time.Time),
+	}
+}
+
+// watch registers a watcher of the prefix, the keys under the prefix which
+// expire are read from the store so that their expiry is delivered
+func (h *watchHub) watch(s Store, prefix []byte) (<-chan Event, func()) {
+	w := &watcher{
+		prefix: append([]byte{}, prefix...),
+		ch:     make(chan Event),
+		mutex:  new(sync.Mutex),
+		signal: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+
+	h.mutex.Lock()
+	h.watchers[w] = struct{}{}
+	h.mutex.Unlock()
+
+	iter := s.IterateWith(IteratorOptions{Prefix: prefix, KeysOnly: true})
+	for iter.Next() {
+		item := iter.Item()
+		if ttl := item.TTL(); !ttl.IsZero() {
+			h.track(string(item.Key()), ttl, false)
+		}
+	}
+	iter.Done()
+
+	var once sync.Once
+	return w.ch, func() {
+		once.Do(func() {
+			h.mutex.Lock()
+			delete(h.watchers, w)
+			h.mutex.Unlock()
+			close(w.done)
+		})
+	}
+}
+
+// publish delivers the events of a committed write
+func (h *watchHub) publish(events ...Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.watchers) == 0 {
+		return
+	}
+	for _, ev := range events {
+		k := string(ev.Key)
+		delete(h.expires, k)
+		if h.deliver(ev) && ev.Type == EventSet && !ev.ExpiresAt.IsZero() {
+			h.track(k, ev.ExpiresAt, true)
+		}
+	}
+}
+
+// track schedules the expiry of a key, expects the caller to hold the lock if
+// locked is true
+func (h *watchHub) track(k string, at time.Time, locked bool) {
+	if !locked {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+	}
+	if _, ok := h.expires[k]; ok && !locked {
+		return
+	}
+	h.expires[k] = at
+	heap.Push(&h.queue, &expiry{key: k, at: at})
+	if h.queue[0].at.Equal(at) {
+		h.schedule()
+	}
+}
+
+// schedule arms the timer for the earliest expiry, expects the caller to hold
+// the lock
+func (h *watchHub) schedule() {
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+	if len(h.queue) == 0 {
+		return
+	}
+	h.timer = time.AfterFunc(time.Until(h.queue[0].at), h.expire)
+}
+
+// expire delivers the expire events of the keys whose expiration has passed
+func (h *watchHub) expire() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	now := time.Now()
+	for len(h.queue) > 0 && !h.queue[0].at.After(now) {
+		e := heap.Pop(&h.queue).(*expiry)
+		// the key was set again or deleted since the expiry was scheduled
+		if at, ok := h.expires[e.key]; !ok || !at.Equal(e.at) {
+			continue
+		}
+		delete(h.expires, e.key)
+		h.deliver(Event{Type: EventExpire, Key: []byte(e.key), ExpiresAt: e.at})
+	}
+	h.schedule()
+}
+
+// deliver queues an event to the watchers of its key, returns false if no
+// watcher is interested in the key; expects the caller to hold the lock
+func (h *watchHub) deliver(ev Event) bool {
+	var ok bool
+	for w := range h.watchers {
+		if bytes.HasPrefix(ev.Key, w.prefix) {
+			w.push(ev)
+			ok = true
+		}
+	}
+	return ok
+}
+
+// close stops every watcher
+func (h *watchHub) close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+	for w := range h.watchers {
+		delete(h.watchers, w)
+		close(w.done)
+	}
+}
+
+func (w *watcher) push(ev Event) {
+	w.mutex.Lock()
+	w.events = append(w.events, ev)
+	w.mutex.Unlock()
+
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+// run forwards the queued events to the channel, the channel is closed once
+// the watcher is cancelled
+func (w *watcher) run() {
+	defer close(w.ch)
+	for {
+		select {
+		case <-w.signal:
+		case <-w.done:
+			return
+		}
+		w.mutex.Lock()
+		events := w.events
+		w.events = nil
+		w.mutex.Unlock()
+
+		for _, ev := range events {
+			select {
+			case w.ch <- ev:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+// expiry is the scheduled expiration of a key
+type expiry struct {
+	key string
+	at  time.Time
+}
+
+// expiryQueue implements heap.Interface ordered by expiration
+type expiryQueue []*expiry
+
+func (q expiryQueue) Len() int            { return len(q) }
+func (q expiryQueue) Less(i, j int) bool  { return q[i].at.Before(q[j].at) }
+func (q expiryQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *expiryQueue) Push(x interface{}) { *q = append(*q, x.(*expiry)) }
+func (q *expiryQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}